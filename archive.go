@@ -0,0 +1,167 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// maxArchiveUploads is how many archive uploads can be in flight at once.
+const maxArchiveUploads = 10
+
+// ArchiveMode decides which messages are archived, and when.
+type ArchiveMode int
+
+const (
+	// ArchiveReceived archives every message as soon as it's received, before it's
+	// processed, for auditing and replay.
+	ArchiveReceived ArchiveMode = iota
+	// ArchiveOutcomes archives every message once it's been handled, along with its outcome.
+	ArchiveOutcomes
+	// ArchiveFailures archives only the messages that couldn't be completed, including
+	// ones that were dead-lettered or dropped, along with the error.
+	ArchiveFailures
+)
+
+// The outcomes recorded in an ArchiveRecord.
+const (
+	OutcomeReceived  = "received"
+	OutcomeCompleted = "completed"
+	OutcomeFailed    = "failed"
+)
+
+// PartialS3Uploader is a partial S3 client that can upload objects.
+type PartialS3Uploader interface {
+	PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error)
+}
+
+// ArchiveConfig configures where and when messages are archived to S3.
+type ArchiveConfig struct {
+	Bucket string
+	// Prefix is prepended to the key of every archived message.
+	Prefix string
+	Mode   ArchiveMode
+	// Key returns the key to archive a message at, after the prefix.  By default
+	// messages are archived at <yyyy>/<mm>/<dd>/<queue name>/<message ID>-<receive count>.json.
+	Key func(rec ArchiveRecord) string
+}
+
+// ArchiveRecord is the JSON document written to S3 for each archived message.
+type ArchiveRecord struct {
+	MessageID         string                                `json:"messageId"`
+	QueueARN          string                                `json:"queueArn"`
+	Body              string                                `json:"body"`
+	Attributes        map[string]string                     `json:"attributes,omitempty"`
+	MessageAttributes map[string]events.SQSMessageAttribute `json:"messageAttributes,omitempty"`
+	ReceiveCount      int                                   `json:"receiveCount"`
+	Outcome           string                                `json:"outcome"`
+	Error             string                                `json:"error,omitempty"`
+	ArchivedAt        time.Time                             `json:"archivedAt"`
+}
+
+// DefaultArchiveKey is the key messages are archived at unless ArchiveConfig.Key is set.
+func DefaultArchiveKey(rec ArchiveRecord) string {
+	return path.Join(rec.ArchivedAt.Format("2006/01/02"), queueName(rec.QueueARN), fmt.Sprintf("%s-%d.json", rec.MessageID, rec.ReceiveCount))
+}
+
+// archiver uploads archived messages in the background so that processing doesn't
+// wait on S3.
+type archiver struct {
+	client PartialS3Uploader
+	config ArchiveConfig
+	logger Logger
+	wg     sync.WaitGroup
+	sem    chan struct{}
+}
+
+func newArchiver(client PartialS3Uploader, config ArchiveConfig) *archiver {
+	if config.Key == nil {
+		config.Key = DefaultArchiveKey
+	}
+	return &archiver{client: client, config: config, sem: make(chan struct{}, maxArchiveUploads)}
+}
+
+// received archives a message that's about to be processed, if the mode asks for it.
+func (a *archiver) received(ctx context.Context, msg events.SQSMessage) {
+	if a == nil || a.config.Mode != ArchiveReceived {
+		return
+	}
+	a.upload(ctx, newArchiveRecord(ctx, msg, OutcomeReceived, nil))
+}
+
+// handled archives a message once its outcome is known, if the mode asks for it.
+func (a *archiver) handled(ctx context.Context, msg events.SQSMessage, err error) {
+	if a == nil || a.config.Mode == ArchiveReceived || (a.config.Mode == ArchiveFailures && err == nil) {
+		return
+	}
+
+	outcome := OutcomeCompleted
+	if err != nil {
+		outcome = OutcomeFailed
+	}
+	a.upload(ctx, newArchiveRecord(ctx, msg, outcome, err))
+}
+
+// upload writes the record to S3 in the background.  Failures are only logged.
+func (a *archiver) upload(ctx context.Context, rec ArchiveRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		a.logger.Printf("failed to archive message %s: %v", rec.MessageID, err)
+		return
+	}
+	key := a.config.Prefix + a.config.Key(rec)
+
+	// the upload shouldn't be cut short when processing is cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.sem <- struct{}{}
+		defer func() { <-a.sem }()
+
+		_, err := a.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(a.config.Bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(data),
+			ContentType: aws.String("application/json"),
+		})
+		if err != nil {
+			a.logger.Printf("failed to archive message %s to s3://%s/%s: %v", rec.MessageID, a.config.Bucket, key, err)
+		}
+	}()
+}
+
+// wait blocks until every upload started so far has finished, so that none are lost
+// when Lambda freezes the function after the invocation.
+func (a *archiver) wait() {
+	if a != nil {
+		a.wg.Wait()
+	}
+}
+
+func newArchiveRecord(ctx context.Context, msg events.SQSMessage, outcome string, err error) ArchiveRecord {
+	rec := ArchiveRecord{
+		MessageID:         msg.MessageId,
+		QueueARN:          msg.EventSourceARN,
+		Body:              msg.Body,
+		Attributes:        msg.Attributes,
+		MessageAttributes: msg.MessageAttributes,
+		ReceiveCount:      ReceiveCount(ctx),
+		Outcome:           outcome,
+		ArchivedAt:        time.Now().UTC(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	return rec
+}
@@ -0,0 +1,134 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// fakeS3Uploader is a PartialS3Uploader that keeps the objects uploaded to it.
+type fakeS3Uploader struct {
+	mu      sync.Mutex
+	objects map[string]ArchiveRecord
+	delay   time.Duration
+}
+
+func (u *fakeS3Uploader) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	time.Sleep(u.delay)
+
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var rec ArchiveRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.objects == nil {
+		u.objects = map[string]ArchiveRecord{}
+	}
+	u.objects[*input.Bucket+"/"+*input.Key] = rec
+	return &s3.PutObjectOutput{}, nil
+}
+
+func archiveProcessor(ctx context.Context, msg events.SQSMessage) error {
+	if msg.MessageId == "bad" {
+		return errors.New("try again")
+	}
+	return nil
+}
+
+func TestWithArchive(t *testing.T) {
+	cases := []struct {
+		mode     ArchiveMode
+		outcomes map[string]string
+	}{
+		{ArchiveReceived, map[string]string{"good": OutcomeReceived, "bad": OutcomeReceived}},
+		{ArchiveOutcomes, map[string]string{"good": OutcomeCompleted, "bad": OutcomeFailed}},
+		{ArchiveFailures, map[string]string{"bad": OutcomeFailed}},
+	}
+
+	for _, c := range cases {
+		uploader := &fakeS3Uploader{delay: 10 * time.Millisecond}
+		handler := NewHandler(&fakeSQSClient{}, archiveProcessor, WithArchive(uploader, ArchiveConfig{
+			Bucket: "archive",
+			Prefix: "messages/",
+			Mode:   c.mode,
+		}))
+
+		handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("good"), testMessage("bad")})
+
+		// uploads must have finished by the time the batch returns
+		if len(uploader.objects) != len(c.outcomes) {
+			t.Errorf("mode %d: expected %d archived messages, got %v", c.mode, len(c.outcomes), uploader.objects)
+		}
+
+		for id, outcome := range c.outcomes {
+			key := "archive/messages/" + time.Now().UTC().Format("2006/01/02") + "/my_queue_name/" + id + "-0.json"
+			rec, ok := uploader.objects[key]
+			if !ok {
+				t.Errorf("mode %d: expected message %s to be archived at %s", c.mode, id, key)
+				continue
+			}
+			if rec.Outcome != outcome || rec.MessageID != id || rec.QueueARN != testQueueARN {
+				t.Errorf("mode %d: unexpected record %+v", c.mode, rec)
+			}
+			if outcome == OutcomeFailed && rec.Error != "try again" {
+				t.Errorf("mode %d: expected the error to be archived, got %q", c.mode, rec.Error)
+			}
+		}
+	}
+}
+
+func TestWithArchiveKey(t *testing.T) {
+	uploader := &fakeS3Uploader{}
+	handler := NewHandler(&fakeSQSClient{}, archiveProcessor, WithArchive(uploader, ArchiveConfig{
+		Bucket: "archive",
+		Key: func(rec ArchiveRecord) string {
+			return strings.ToUpper(rec.MessageID)
+		},
+	}))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("good")})
+
+	if _, ok := uploader.objects["archive/GOOD"]; !ok {
+		t.Errorf("expected the custom key to be used, got %v", uploader.objects)
+	}
+}
+
+func TestWithArchivePermanentFailures(t *testing.T) {
+	for _, mode := range []ArchiveMode{ArchiveOutcomes, ArchiveFailures} {
+		for _, deadLetter := range []string{testDeadLetterURL, ""} {
+			uploader := &fakeS3Uploader{}
+			handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+				return Permanent(errors.New("bad payload"))
+			}, WithDeadLetterQueue(deadLetter), WithArchive(uploader, ArchiveConfig{Bucket: "archive", Mode: mode}), WithLogger(nil))
+
+			handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("bad")})
+
+			// dead-lettered and dropped messages are archived as the failures they are
+			if len(uploader.objects) != 1 {
+				t.Fatalf("mode %d with dead-letter queue %q: expected the message to be archived, got %v", mode, deadLetter, uploader.objects)
+			}
+			for _, rec := range uploader.objects {
+				if rec.Outcome != OutcomeFailed || rec.Error != "bad payload" {
+					t.Errorf("mode %d with dead-letter queue %q: expected a failure, got %+v", mode, deadLetter, rec)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package sqsworker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// ErrAttributeNotFound is returned when reading a message attribute the message doesn't
+// have, or that doesn't hold the kind of value asked for.
+var ErrAttributeNotFound = errors.New("message attribute not found")
+
+// Attributes reads the message attributes of a received message by type.
+type Attributes map[string]events.SQSMessageAttribute
+
+// Attr returns the message attributes of a message for reading by type, as in
+// Attr(msg).String("type").
+func Attr(msg events.SQSMessage) Attributes {
+	return msg.MessageAttributes
+}
+
+// Has reports whether the attribute is set.
+func (a Attributes) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// String returns the value of a String or Number attribute.
+func (a Attributes) String(name string) (string, error) {
+	attr, ok := a[name]
+	if !ok || attr.StringValue == nil {
+		return "", fmt.Errorf("%w: %s", ErrAttributeNotFound, name)
+	}
+	return *attr.StringValue, nil
+}
+
+// Int returns the value of an attribute holding a whole number.
+func (a Attributes) Int(name string) (int64, error) {
+	value, err := a.String(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("message attribute %s isn't a whole number: %w", name, err)
+	}
+	return n, nil
+}
+
+// Float returns the value of an attribute holding a number.
+func (a Attributes) Float(name string) (float64, error) {
+	value, err := a.String(name)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("message attribute %s isn't a number: %w", name, err)
+	}
+	return f, nil
+}
+
+// Bool returns the value of an attribute holding true or false.  SQS has no boolean
+// type, so these are sent as strings, as AttributeMap.Bool does.
+func (a Attributes) Bool(name string) (bool, error) {
+	value, err := a.String(name)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("message attribute %s isn't a boolean: %w", name, err)
+	}
+	return b, nil
+}
+
+// Binary returns the value of a Binary attribute.
+func (a Attributes) Binary(name string) ([]byte, error) {
+	attr, ok := a[name]
+	if !ok || attr.BinaryValue == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAttributeNotFound, name)
+	}
+	return attr.BinaryValue, nil
+}
+
+// JSON decodes the JSON value of a String or Binary attribute into v.
+func (a Attributes) JSON(name string, v interface{}) error {
+	data, err := a.Binary(name)
+	if err != nil {
+		value, strErr := a.String(name)
+		if strErr != nil {
+			return strErr
+		}
+		data = []byte(value)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode message attribute %s: %w", name, err)
+	}
+	return nil
+}
+
+// AttributeMap builds the message attributes of a message being sent, and can be used
+// anywhere the SDK takes them, such as SendMessageInput.MessageAttributes.
+type AttributeMap map[string]*sqs.MessageAttributeValue
+
+// NewAttributeMap creates an empty AttributeMap.
+func NewAttributeMap() AttributeMap {
+	return AttributeMap{}
+}
+
+// AttributesOf creates an AttributeMap holding the attributes of a received message, for
+// sending it on.  If the message has more attributes than SQS allows, the first in name
+// order are kept.
+func AttributesOf(msg events.SQSMessage) AttributeMap {
+	return toMessageAttributes(msg.MessageAttributes)
+}
+
+// String sets a String attribute.
+func (m AttributeMap) String(name, value string) AttributeMap {
+	m[name] = stringAttribute(value)
+	return m
+}
+
+// Int sets a Number attribute to a whole number.
+func (m AttributeMap) Int(name string, value int64) AttributeMap {
+	m[name] = numberAttribute(strconv.FormatInt(value, 10))
+	return m
+}
+
+// Float sets a Number attribute.
+func (m AttributeMap) Float(name string, value float64) AttributeMap {
+	m[name] = numberAttribute(strconv.FormatFloat(value, 'f', -1, 64))
+	return m
+}
+
+// Bool sets a String attribute to true or false.
+func (m AttributeMap) Bool(name string, value bool) AttributeMap {
+	m[name] = stringAttribute(strconv.FormatBool(value))
+	return m
+}
+
+// Binary sets a Binary attribute.
+func (m AttributeMap) Binary(name string, value []byte) AttributeMap {
+	dataType := "Binary"
+	m[name] = &sqs.MessageAttributeValue{DataType: &dataType, BinaryValue: value}
+	return m
+}
+
+// Delete removes an attribute.
+func (m AttributeMap) Delete(name string) AttributeMap {
+	delete(m, name)
+	return m
+}
+
+// numberAttribute creates a message attribute holding a number.
+func numberAttribute(value string) *sqs.MessageAttributeValue {
+	dataType := "Number"
+	return &sqs.MessageAttributeValue{DataType: &dataType, StringValue: &value}
+}
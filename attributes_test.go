@@ -0,0 +1,95 @@
+package sqsworker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestAttributes(t *testing.T) {
+	msg := testMessage("1")
+	msg.MessageAttributes = map[string]events.SQSMessageAttribute{
+		"type":    {DataType: "String", StringValue: aws.String("order")},
+		"count":   {DataType: "Number", StringValue: aws.String("42")},
+		"price":   {DataType: "Number", StringValue: aws.String("9.99")},
+		"urgent":  {DataType: "String", StringValue: aws.String("true")},
+		"payload": {DataType: "Binary", BinaryValue: []byte(`{"id":"abc"}`)},
+	}
+	attrs := Attr(msg)
+
+	if value, err := attrs.String("type"); value != "order" || err != nil {
+		t.Errorf("expected the string attribute, got %q: %v", value, err)
+	}
+	if n, err := attrs.Int("count"); n != 42 || err != nil {
+		t.Errorf("expected the number attribute, got %d: %v", n, err)
+	}
+	if f, err := attrs.Float("price"); f != 9.99 || err != nil {
+		t.Errorf("expected the number attribute, got %g: %v", f, err)
+	}
+	if b, err := attrs.Bool("urgent"); !b || err != nil {
+		t.Errorf("expected the boolean attribute, got %v: %v", b, err)
+	}
+	if data, err := attrs.Binary("payload"); string(data) != `{"id":"abc"}` || err != nil {
+		t.Errorf("expected the binary attribute, got %q: %v", data, err)
+	}
+
+	var payload struct{ ID string }
+	if err := attrs.JSON("payload", &payload); payload.ID != "abc" || err != nil {
+		t.Errorf("expected the binary attribute to be decoded, got %+v: %v", payload, err)
+	}
+
+	if _, err := attrs.String("missing"); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("expected a missing attribute to be reported, got %v", err)
+	}
+	if _, err := attrs.Binary("type"); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("expected a string attribute not to be read as binary, got %v", err)
+	}
+	if _, err := attrs.Int("type"); err == nil || errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("expected an attribute that isn't a number to be reported, got %v", err)
+	}
+	if !attrs.Has("type") || attrs.Has("missing") {
+		t.Error("expected Has to report which attributes are set")
+	}
+}
+
+func TestAttributeMap(t *testing.T) {
+	attrs := NewAttributeMap().
+		String("type", "order").
+		Int("count", 42).
+		Float("price", 9.99).
+		Bool("urgent", true).
+		Binary("payload", []byte("data"))
+
+	expected := map[string][2]string{
+		"type":   {"String", "order"},
+		"count":  {"Number", "42"},
+		"price":  {"Number", "9.99"},
+		"urgent": {"String", "true"},
+	}
+	for name, want := range expected {
+		attr := attrs[name]
+		if got := [2]string{aws.StringValue(attr.DataType), aws.StringValue(attr.StringValue)}; got != want {
+			t.Errorf("expected %s to be %v, got %v", name, want, got)
+		}
+	}
+	if attr := attrs["payload"]; aws.StringValue(attr.DataType) != "Binary" || string(attr.BinaryValue) != "data" {
+		t.Errorf("expected a binary attribute, got %v", attr)
+	}
+}
+
+func TestAttributesOf(t *testing.T) {
+	msg := testMessage("1")
+	msg.MessageAttributes = map[string]events.SQSMessageAttribute{
+		"type":           {DataType: "String", StringValue: aws.String("order")},
+		FailureAttribute: {DataType: "String", StringValue: aws.String("{}")},
+	}
+
+	attrs := AttributesOf(msg).Delete(FailureAttribute).Int("attempt", 2)
+
+	if names := sortedKeys(attrs); !reflect.DeepEqual(names, []string{"attempt", "type"}) {
+		t.Errorf("expected the message's attributes with the changes, got %v", names)
+	}
+}
@@ -0,0 +1,69 @@
+package sqsworker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// MessageError is the error for a single message in a batch that couldn't be completed.
+type MessageError struct {
+	MessageID      string
+	EventSourceARN string
+	Err            error
+}
+
+func newMessageError(msg events.SQSMessage, err error) *MessageError {
+	return &MessageError{MessageID: msg.MessageId, EventSourceARN: msg.EventSourceARN, Err: err}
+}
+
+func (e *MessageError) Error() string {
+	return fmt.Sprintf("message %s: %v", e.MessageID, e.Err)
+}
+
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError is returned when some of the messages in a batch couldn't be completed.
+// It holds a MessageError for each of them, and errors.Is and errors.As look through
+// all of them in the same way as an error made with errors.Join.
+type BatchError struct {
+	Total  int
+	Errors []*MessageError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("failed to complete all given messages (%d of %d failed): %s",
+		len(e.Errors), e.Total, strings.Join(msgs, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// newBatchError collects the failed outcomes of a batch, returning nil if there are none.
+func newBatchError(total int, outcomes []outcome) error {
+	var errs []*MessageError
+	for _, res := range outcomes {
+		if res.err != nil {
+			errs = append(errs, newMessageError(res.msg, res.err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &BatchError{Total: total, Errors: errs}
+}
@@ -0,0 +1,58 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestBatchError(t *testing.T) {
+	errDown := errors.New("downstream unavailable")
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "bad" {
+			return errDown
+		}
+		return nil
+	})
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		testMessage("good"),
+		testMessage("bad"),
+	})
+	if completed != 1 {
+		t.Errorf("expected 1 completed message, got %d", completed)
+	}
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	if batchErr.Total != 2 || len(batchErr.Errors) != 1 {
+		t.Fatalf("expected 1 of 2 messages to fail, got %+v", batchErr)
+	}
+
+	msgErr := batchErr.Errors[0]
+	if msgErr.MessageID != "bad" || msgErr.EventSourceARN != testQueueARN {
+		t.Errorf("unexpected message error %+v", msgErr)
+	}
+	if !errors.Is(err, errDown) {
+		t.Errorf("expected the batch error to wrap the processor's error, got %v", err)
+	}
+
+	var found *MessageError
+	if !errors.As(errors.Join(errors.New("other"), err), &found) || found.MessageID != "bad" {
+		t.Errorf("expected to find the message error through errors.Join, got %v", found)
+	}
+}
+
+func TestBatchErrorNil(t *testing.T) {
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	})
+
+	if _, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
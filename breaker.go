@@ -0,0 +1,54 @@
+package sqsworker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is reported for messages that weren't processed because the circuit
+// breaker was open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// circuitBreaker stops the processor being called after a run of transient failures,
+// on the assumption that a dependency is down.  It lives on the handler, so a tripped
+// breaker stays open across warm invocations until its cooldown has passed.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu          sync.Mutex
+	consecutive int
+	openUntil   time.Time
+}
+
+// allow reports whether the processor may be called.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !time.Now().Before(b.openUntil)
+}
+
+// record tracks the result of a processor call.  Only transient failures count towards
+// tripping the breaker, since permanent ones are down to the message itself.  Once the
+// cooldown has passed, a single failure is enough to trip it again.
+func (b *circuitBreaker) record(class ErrorClass, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		b.consecutive = 0
+		b.openUntil = time.Time{}
+		return
+	}
+
+	if class == ClassPermanent {
+		return
+	}
+
+	b.consecutive++
+	if b.consecutive >= b.threshold || !b.openUntil.IsZero() {
+		b.consecutive = 0
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,68 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithCircuitBreaker(t *testing.T) {
+	client := &fakeSQSClient{}
+	calls := 0
+	healthy := false
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		calls++
+		if healthy {
+			return nil
+		}
+		return errors.New("dependency down")
+	},
+		WithRetry(ExponentialBackoff{MaxRetries: 5}),
+		WithCircuitBreaker(2, 30*time.Millisecond),
+	)
+
+	ctx := context.Background()
+	handler.ProcessMessages(ctx, []events.SQSMessage{testMessage("1")})
+	if calls != 2 {
+		t.Fatalf("expected the breaker to trip after 2 failures, got %d calls", calls)
+	}
+
+	// the breaker stays open for the next invocation
+	outcomes := handler.processBatch(ctx, []events.SQSMessage{testMessage("2")})
+	if calls != 2 || !errors.Is(outcomes[0].err, ErrCircuitOpen) {
+		t.Errorf("expected the open breaker to skip the processor, got %d calls and %v", calls, outcomes[0].err)
+	}
+
+	// a single failure after the cooldown trips it again
+	time.Sleep(40 * time.Millisecond)
+	handler.ProcessMessages(ctx, []events.SQSMessage{testMessage("3")})
+	if calls != 3 {
+		t.Errorf("expected one trial call after the cooldown, got %d calls", calls)
+	}
+
+	// and a success after the next cooldown closes it
+	time.Sleep(40 * time.Millisecond)
+	healthy = true
+	completed, err := handler.ProcessMessages(ctx, []events.SQSMessage{testMessage("4"), testMessage("5")})
+	if err != nil || completed != 2 {
+		t.Errorf("expected the breaker to close after a success, got %d and %v", completed, err)
+	}
+}
+
+func TestCircuitBreakerIgnoresPermanentFailures(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 1, cooldown: time.Minute}
+	breaker.record(ClassPermanent, true)
+
+	if !breaker.allow() {
+		t.Error("expected permanent failures not to trip the breaker")
+	}
+
+	breaker.record(ClassTransient, true)
+	if breaker.allow() {
+		t.Error("expected a transient failure to trip the breaker")
+	}
+}
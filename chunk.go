@@ -0,0 +1,71 @@
+package sqsworker
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// processChunks handles a batch in chunks of the handler's chunk size, one chunk after
+// another, so that batches of thousands of messages aren't all started at once.  Each
+// chunk's messages are deleted before the next chunk starts.  Once the deadline margin
+// is reached, the rest of the batch is reported as failing with ErrDeadline without
+// being started.
+func (s *Handler) processChunks(ctx context.Context, messages []events.SQSMessage) []outcome {
+	if s.chunkSize <= 0 || len(messages) <= s.chunkSize {
+		return s.processBatch(ctx, messages)
+	}
+
+	deadlineCtx, cancel := s.withDeadlineMargin(ctx)
+	defer cancel()
+
+	outcomes := make([]outcome, 0, len(messages))
+	failedGroups := map[string]bool{}
+	var stopped error
+
+	for start := 0; start < len(messages); start += s.chunkSize {
+		chunk := messages[start:min(start+s.chunkSize, len(messages))]
+
+		if stopped == nil && deadlineCtx.Err() != nil {
+			s.logger.Printf("stopping with %d message(s) left as the deadline is near", len(messages)-start)
+			stopped = ErrDeadline
+		}
+		if stopped != nil {
+			for _, msg := range chunk {
+				outcomes = append(outcomes, outcome{msg: msg, err: stopped})
+			}
+			continue
+		}
+
+		// later messages in a FIFO group that's already failed mustn't overtake it
+		if s.fifo && !s.sequential {
+			var remaining []events.SQSMessage
+			for _, msg := range chunk {
+				if failedGroups[msg.Attributes["MessageGroupId"]] {
+					outcomes = append(outcomes, outcome{msg: msg, err: ErrSkipped})
+				} else {
+					remaining = append(remaining, msg)
+				}
+			}
+			chunk = remaining
+		}
+
+		for _, res := range s.processBatch(ctx, chunk) {
+			outcomes = append(outcomes, res)
+			if res.err == nil {
+				continue
+			}
+
+			switch {
+			case s.sequential:
+				stopped = ErrSkipped
+			case s.failFast && failsBatch(res):
+				stopped = ErrCancelled
+			case s.fifo:
+				failedGroups[res.msg.Attributes["MessageGroupId"]] = true
+			}
+		}
+	}
+
+	return outcomes
+}
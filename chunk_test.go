@@ -0,0 +1,124 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// testMessages creates n messages from the test queue.
+func testMessages(n int) []events.SQSMessage {
+	messages := make([]events.SQSMessage, n)
+	for i := range messages {
+		messages[i] = testMessage(strconv.Itoa(i))
+	}
+	return messages
+}
+
+func TestWithChunkSize(t *testing.T) {
+	client := &fakeSQSClient{}
+	var mu sync.Mutex
+	running, peak := 0, 0
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}, WithChunkSize(10), WithBatchDelete(), WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), testMessages(35))
+	if completed != 35 || err != nil {
+		t.Errorf("expected every message to complete, got %d: %v", completed, err)
+	}
+	if peak > 10 {
+		t.Errorf("expected at most a chunk of messages at once, got %d", peak)
+	}
+	if client.batches != 4 || len(client.deleted) != 35 {
+		t.Errorf("expected a batch delete for each chunk, got %d deleting %d", client.batches, len(client.deleted))
+	}
+}
+
+func TestChunkSizeDeadline(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}, WithChunkSize(2), WithDeadlineMargin(time.Second), WithLogger(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second+50*time.Millisecond)
+	defer cancel()
+
+	completed, err := handler.ProcessMessages(ctx, testMessages(10))
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || completed == 0 || completed+len(batchErr.Errors) != 10 {
+		t.Fatalf("expected the batch to stop part way through, got %d: %v", completed, err)
+	}
+	for _, msgErr := range batchErr.Errors {
+		if !errors.Is(msgErr.Err, ErrDeadline) {
+			t.Errorf("expected the rest of the batch to be left for the deadline, got %v", msgErr.Err)
+		}
+	}
+	if len(client.deleted) != completed {
+		t.Errorf("expected only the completed messages to be deleted, got %v", client.deleted)
+	}
+}
+
+func TestChunkSizeFIFO(t *testing.T) {
+	var processed []string
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		processed = append(processed, msg.MessageId)
+		if msg.MessageId == "a1" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithChunkSize(2), WithFIFO(), WithMaxConcurrency(1), WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		fifoMessage("a1", "a", "1"), fifoMessage("b1", "b", "2"),
+		fifoMessage("a2", "a", "3"), fifoMessage("b2", "b", "4"),
+	})
+
+	if completed != 2 || len(processed) != 3 {
+		t.Errorf("expected only the b group to complete, got %d of %v", completed, processed)
+	}
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 2 {
+		t.Fatalf("expected the a group to fail, got %v", err)
+	}
+	for _, msgErr := range batchErr.Errors {
+		if msgErr.MessageID == "a2" && !errors.Is(msgErr.Err, ErrSkipped) {
+			t.Errorf("expected the later message in the failed group to be skipped, got %v", msgErr.Err)
+		}
+	}
+}
+
+func TestChunkSizeSequential(t *testing.T) {
+	var processed []string
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		processed = append(processed, msg.MessageId)
+		if msg.MessageId == "1" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithChunkSize(2), WithSequential(), WithLogger(nil))
+
+	completed, _ := handler.ProcessMessages(context.Background(), testMessages(6))
+	if completed != 1 || len(processed) != 2 {
+		t.Errorf("expected processing to stop at the failure, got %d of %v", completed, processed)
+	}
+}
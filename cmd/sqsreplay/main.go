@@ -0,0 +1,181 @@
+// Command sqsreplay captures SQS messages to JSON files and replays them, for
+// reproducing problems with messages outside of production.
+//
+//	sqsreplay capture -queue URL -dir DIR [-max N]
+//	sqsreplay requeue -queue URL FILE...
+//	sqsreplay invoke -function NAME [-lambda-endpoint URL] FILE...
+//
+// capture receives messages from a queue, without deleting them, and writes each batch
+// to DIR as an SQS event.  requeue sends the messages in captured events to a queue.
+// invoke calls a Lambda function with each captured event, such as a function running
+// locally behind the Lambda runtime interface emulator.  Files can also be events
+// written by a handler using sqsworker.WithCapture.
+//
+// The SQS endpoint can be overridden with SQSWORKER_ENDPOINT, AWS_ENDPOINT_URL_SQS or
+// AWS_ENDPOINT_URL.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	sqsworker "github.com/helpfulhuman/lambda-sqs-worker"
+)
+
+const usage = `usage:
+  sqsreplay capture -queue URL -dir DIR [-max N]
+  sqsreplay requeue -queue URL FILE...
+  sqsreplay invoke -function NAME [-lambda-endpoint URL] FILE...`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "capture":
+		err = capture(ctx, os.Args[2:])
+	case "requeue":
+		err = requeue(os.Args[2:])
+	case "invoke":
+		err = invoke(ctx, os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", os.Args[1], usage)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func capture(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("capture", flag.ExitOnError)
+	queueURL := flags.String("queue", "", "URL of the queue to capture messages from")
+	dir := flags.String("dir", ".", "directory to write events to")
+	limit := flags.Int("max", 10, "most messages to capture")
+	flags.Parse(args)
+
+	if *queueURL == "" {
+		return errors.New("capture: -queue is required")
+	}
+
+	client, err := newSQSClient()
+	if err != nil {
+		return err
+	}
+
+	paths, err := sqsworker.CaptureQueue(ctx, client, *queueURL, *dir, *limit)
+	for _, path := range paths {
+		fmt.Println(path)
+	}
+	return err
+}
+
+func requeue(args []string) error {
+	flags := flag.NewFlagSet("requeue", flag.ExitOnError)
+	queueURL := flags.String("queue", "", "URL of the queue to send messages to")
+	flags.Parse(args)
+
+	if *queueURL == "" || flags.NArg() == 0 {
+		return errors.New("requeue: -queue and at least one file are required")
+	}
+
+	client, err := newSQSClient()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range flags.Args() {
+		ev, err := sqsworker.ReadEvent(path)
+		if err != nil {
+			return err
+		}
+		if err := sqsworker.Requeue(client, *queueURL, ev); err != nil {
+			return err
+		}
+		fmt.Printf("%s: requeued %d message(s)\n", filepath.Base(path), len(ev.Records))
+	}
+
+	return nil
+}
+
+func invoke(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("invoke", flag.ExitOnError)
+	function := flags.String("function", "", "name of the Lambda function to invoke")
+	endpoint := flags.String("lambda-endpoint", "", "Lambda endpoint, such as http://localhost:9000 for the runtime interface emulator")
+	flags.Parse(args)
+
+	if *function == "" || flags.NArg() == 0 {
+		return errors.New("invoke: -function and at least one file are required")
+	}
+
+	config := aws.NewConfig()
+	if *endpoint != "" {
+		config = config.WithEndpoint(*endpoint)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return err
+	}
+	client := lambda.New(sess)
+
+	for _, path := range flags.Args() {
+		ev, err := sqsworker.ReadEvent(path)
+		if err != nil {
+			return err
+		}
+
+		payload, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+
+		out, err := client.InvokeWithContext(ctx, &lambda.InvokeInput{
+			FunctionName: function,
+			Payload:      payload,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to invoke %s with %s: %w", *function, path, err)
+		}
+
+		result := "ok"
+		if out.FunctionError != nil {
+			result = *out.FunctionError
+		}
+		fmt.Printf("%s: %s %s\n", filepath.Base(path), result, out.Payload)
+	}
+
+	return nil
+}
+
+// newSQSClient creates an SQS client from the ambient AWS configuration, using the
+// same endpoint overrides as sqsworker.NewHandlerFromEnv.
+func newSQSClient() (*sqs.SQS, error) {
+	config := aws.NewConfig()
+	if endpoint, ok := sqsworker.EndpointFromEnv(); ok {
+		config = config.WithEndpoint(endpoint)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqs.New(sess), nil
+}
@@ -0,0 +1,83 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-lambda-go/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec decodes message bodies into Go values for typed processors.
+type Codec interface {
+	Decode(ctx context.Context, body []byte, v interface{}) error
+}
+
+// JSONCodec is a Codec for JSON bodies.
+type JSONCodec struct{}
+
+// Decode implements the Codec interface.
+func (JSONCodec) Decode(ctx context.Context, body []byte, v interface{}) error {
+	return json.Unmarshal(body, v)
+}
+
+// ProtobufCodec is a Codec for protobuf messages, which are base64 encoded to be sent
+// through SQS.  Values must be protobuf messages.
+type ProtobufCodec struct{}
+
+// Decode implements the Codec interface.
+func (ProtobufCodec) Decode(ctx context.Context, body []byte, v interface{}) error {
+	msg, err := protoTarget(v)
+	if err != nil {
+		return err
+	}
+
+	data, err := decodeBase64(body)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(data, msg)
+}
+
+// protoTarget finds the protobuf message to decode into.  Typed processors pass a
+// pointer to their value, which for generated message types is a pointer to a nil
+// message pointer that needs allocating first.
+func protoTarget(v interface{}) (proto.Message, error) {
+	if msg, ok := v.(proto.Message); ok {
+		return msg, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Ptr {
+		if rv.Elem().IsNil() {
+			rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+		}
+		if msg, ok := rv.Elem().Interface().(proto.Message); ok {
+			return msg, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%T is not a protobuf message", v)
+}
+
+// Typed creates a MessageProcessor that decodes each message body with the codec and
+// hands the decoded value to the processor along with the message.  A nil codec means
+// JSON.  Bodies that can't be decoded fail permanently, unless the codec marks the
+// error as transient.
+func Typed[T any](codec Codec, processor func(ctx context.Context, v T, msg events.SQSMessage) error) MessageProcessor {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+
+	return func(ctx context.Context, msg events.SQSMessage) error {
+		var v T
+		if err := codec.Decode(ctx, []byte(msg.Body), &v); err != nil {
+			return permanentByDefault(fmt.Errorf("failed to decode message %s: %w", msg.MessageId, err))
+		}
+
+		return processor(ctx, v, msg)
+	}
+}
@@ -0,0 +1,67 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type testOrder struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+}
+
+func TestTypedJSON(t *testing.T) {
+	var order testOrder
+	processor := Typed(nil, func(ctx context.Context, v testOrder, msg events.SQSMessage) error {
+		order = v
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = `{"id":1,"email":"a@example.com"}`
+	if err := processor(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != 1 || order.Email != "a@example.com" {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	msg.Body = `not json`
+	if err := processor(context.Background(), msg); classifyError(nil, err) != ClassPermanent {
+		t.Errorf("expected invalid bodies to fail permanently, got %v", err)
+	}
+}
+
+func TestTypedProtobuf(t *testing.T) {
+	data, err := proto.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	processor := Typed(ProtobufCodec{}, func(ctx context.Context, v *wrapperspb.StringValue, msg events.SQSMessage) error {
+		value = v.GetValue()
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = base64.StdEncoding.EncodeToString(data)
+	if err := processor(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if value != "hello" {
+		t.Errorf("expected the decoded protobuf value, got %q", value)
+	}
+}
+
+func TestProtobufCodecRejectsOtherTypes(t *testing.T) {
+	var order testOrder
+	if err := (ProtobufCodec{}).Decode(context.Background(), []byte("AA=="), &order); err == nil {
+		t.Error("expected decoding into a non-protobuf type to fail")
+	}
+}
@@ -0,0 +1,43 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrPoisonMessage is reported for messages that exceeded the maximum receive count.
+var ErrPoisonMessage = errors.New("message exceeded the maximum receive count")
+
+type contextKey int
+
+const (
+	receiveCountKey contextKey = iota
+	snsKey
+	eventBridgeKey
+	outputKey
+	correlationIDKey
+	logFieldsKey
+	failFastKey
+	loggerKey
+	invocationKey
+)
+
+// ReceiveCount returns the approximate number of times the message being processed
+// has been received from the queue, or zero if it isn't known.
+func ReceiveCount(ctx context.Context) int {
+	count, _ := ctx.Value(receiveCountKey).(int)
+	return count
+}
+
+func withReceiveCount(ctx context.Context, count int) context.Context {
+	return context.WithValue(ctx, receiveCountKey, count)
+}
+
+// receiveCount reads the ApproximateReceiveCount system attribute of a message.
+func receiveCount(msg events.SQSMessage) int {
+	count, _ := strconv.Atoi(msg.Attributes["ApproximateReceiveCount"])
+	return count
+}
@@ -0,0 +1,54 @@
+package sqsworker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestReceiveCount(t *testing.T) {
+	client := &fakeSQSClient{}
+	seen := 0
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		seen = ReceiveCount(ctx)
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Attributes = map[string]string{"ApproximateReceiveCount": "4"}
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{msg})
+
+	if seen != 4 {
+		t.Errorf("expected the processor to see a receive count of 4, got %d", seen)
+	}
+	if ReceiveCount(context.Background()) != 0 {
+		t.Error("expected a zero receive count outside of a handler")
+	}
+}
+
+func TestWithMaxReceiveCount(t *testing.T) {
+	client := &fakeSQSClient{}
+	calls := 0
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		calls++
+		return nil
+	}, WithMaxReceiveCount(5), WithDeadLetterQueue(testDeadLetterURL))
+
+	fresh := testMessage("fresh")
+	fresh.Attributes = map[string]string{"ApproximateReceiveCount": "5"}
+	poison := testMessage("poison")
+	poison.Attributes = map[string]string{"ApproximateReceiveCount": "6"}
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{fresh, poison})
+
+	if err != nil || completed != 2 {
+		t.Fatalf("expected both messages to be closed, got %d completed and error %v", completed, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the poison message to skip the processor, got %d calls", calls)
+	}
+	if len(client.sent) != 1 || *client.sent[0].MessageBody != poison.Body {
+		t.Error("expected the poison message to be dead-lettered")
+	}
+}
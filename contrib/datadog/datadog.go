@@ -0,0 +1,194 @@
+// Package datadog instruments an sqsworker.Handler for Datadog.  Metrics are sent to
+// the Datadog Lambda extension as distributions, and each message is traced with
+// dd-trace-go, continuing the trace its producer started when the producer attached a
+// _datadog message attribute.
+package datadog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/aws/aws-lambda-go/events"
+	sqsworker "github.com/helpfulhuman/lambda-sqs-worker"
+)
+
+// TraceContextAttribute is the message attribute Datadog's tracers put the trace
+// context of a message's producer in, as JSON.
+const TraceContextAttribute = "_datadog"
+
+// Config configures the Datadog integration.  The zero value sends metrics prefixed
+// with sqsworker to the extension and reports spans under the tracer's service.
+type Config struct {
+	// Prefix is prefixed to each metric name, and defaults to sqsworker.
+	Prefix string
+	// Addr is the address metrics are sent to, and defaults to the extension's.
+	Addr string
+	// Tags are added to every metric, such as env:prod.
+	Tags []string
+	// ServiceName is the service spans are reported under.
+	ServiceName string
+}
+
+// Options returns the handler options that send metrics and traces to Datadog.  The
+// tracer itself should be started with tracer.Start as normal.
+func Options(config Config) ([]sqsworker.Option, error) {
+	recorder, err := NewRecorder(config)
+	if err != nil {
+		return nil, err
+	}
+	return []sqsworker.Option{
+		sqsworker.WithMetrics(recorder),
+		sqsworker.WithMiddleware(Trace(config.ServiceName)),
+	}, nil
+}
+
+// Recorder is an sqsworker.MetricsRecorder that sends every metric to the Datadog Lambda
+// extension as a distribution, tagged with the queue.  Metrics that fail to send are
+// dropped.
+type Recorder struct {
+	conn   net.Conn
+	prefix string
+	tags   string
+}
+
+// NewRecorder creates a Recorder from the metrics parts of the config.
+func NewRecorder(config Config) (*Recorder, error) {
+	addr := config.Addr
+	if addr == "" {
+		addr = sqsworker.DatadogExtensionAddr
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the Datadog extension at %s: %w", addr, err)
+	}
+
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "sqsworker"
+	}
+
+	var tags string
+	for _, tag := range config.Tags {
+		tags += "," + tagReplacer.Replace(tag)
+	}
+
+	return &Recorder{conn: conn, prefix: nameReplacer.Replace(prefix), tags: tags}, nil
+}
+
+// nameReplacer and tagReplacer replace the characters the DogStatsD line format uses
+// as separators in metric names and tags, as sqsworker.StatsDRecorder does.  Tags keep
+// their colons, which separate their names from their values.
+var (
+	nameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_")
+	tagReplacer  = strings.NewReplacer("|", "_", "@", "_", "#", "_", ",", "_")
+)
+
+// MessageReceived implements sqsworker.MetricsRecorder.
+func (r *Recorder) MessageReceived(queue string) {
+	r.send("messages.received", queue, 1)
+}
+
+// MessageSucceeded implements sqsworker.MetricsRecorder.
+func (r *Recorder) MessageSucceeded(queue string) {
+	r.send("messages.succeeded", queue, 1)
+}
+
+// MessageFailed implements sqsworker.MetricsRecorder.
+func (r *Recorder) MessageFailed(queue string) {
+	r.send("messages.failed", queue, 1)
+}
+
+// MessageDeleted implements sqsworker.MetricsRecorder.
+func (r *Recorder) MessageDeleted(queue string) {
+	r.send("messages.deleted", queue, 1)
+}
+
+// ShadowSucceeded implements sqsworker.ShadowRecorder.
+func (r *Recorder) ShadowSucceeded(queue string) {
+	r.send("shadow.succeeded", queue, 1)
+}
+
+// ShadowFailed implements sqsworker.ShadowRecorder.
+func (r *Recorder) ShadowFailed(queue string) {
+	r.send("shadow.failed", queue, 1)
+}
+
+// ObserveDuration implements sqsworker.MetricsRecorder in milliseconds.
+func (r *Recorder) ObserveDuration(queue string, d time.Duration) {
+	r.send("processing.duration", queue, float64(d)/float64(time.Millisecond))
+}
+
+// Close closes the connection to the extension.
+func (r *Recorder) Close() error {
+	return r.conn.Close()
+}
+
+// send writes a distribution in the DogStatsD format.
+func (r *Recorder) send(name, queue string, value float64) {
+	fmt.Fprintf(r.conn, "%s.%s:%g|d|#queue:%s%s", r.prefix, name, value, tagReplacer.Replace(queue), r.tags)
+}
+
+// Trace is a Middleware that runs the processor in a span, so that spans started by
+// the processor from its context belong to the message.  Messages with a trace context
+// attribute continue their producer's trace.  Failed messages have their spans marked
+// with the error.
+func Trace(service string) sqsworker.Middleware {
+	return func(next sqsworker.MessageProcessor) sqsworker.MessageProcessor {
+		return func(ctx context.Context, msg events.SQSMessage) error {
+			opts := []tracer.StartSpanOption{
+				tracer.SpanType(ext.SpanTypeMessageConsumer),
+				tracer.ResourceName(queueName(msg.EventSourceARN)),
+				tracer.Tag("messaging.system", "aws_sqs"),
+				tracer.Tag("messaging.message_id", msg.MessageId),
+				tracer.Tag("aws.sqs.receive_count", sqsworker.ReceiveCount(ctx)),
+			}
+			if service != "" {
+				opts = append(opts, tracer.ServiceName(service))
+			}
+			if parent := extractTraceContext(msg); parent != nil {
+				opts = append(opts, tracer.ChildOf(parent))
+			}
+
+			span, ctx := tracer.StartSpanFromContext(ctx, "sqs.process", opts...)
+			err := next(ctx, msg)
+			span.Finish(tracer.WithError(err))
+			return err
+		}
+	}
+}
+
+// extractTraceContext reads the producer's trace context from a message, if it has one.
+// Producers publishing through SNS with raw message delivery send it as binary.
+func extractTraceContext(msg events.SQSMessage) *tracer.SpanContext {
+	attr, ok := msg.MessageAttributes[TraceContextAttribute]
+	if !ok {
+		return nil
+	}
+
+	data := attr.BinaryValue
+	if attr.StringValue != nil {
+		data = []byte(*attr.StringValue)
+	}
+
+	carrier := tracer.TextMapCarrier{}
+	if err := json.Unmarshal(data, &carrier); err != nil {
+		return nil
+	}
+
+	parent, err := tracer.Extract(carrier)
+	if err != nil {
+		return nil
+	}
+	return parent
+}
+
+// queueName returns the name of the queue with the given ARN.
+func queueName(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
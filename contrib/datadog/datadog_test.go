@@ -0,0 +1,135 @@
+package datadog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/mocktracer"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const testQueueARN = "arn:aws:sqs:us-west-2:123456:orders"
+
+func TestRecorder(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	recorder, err := NewRecorder(Config{Addr: server.LocalAddr().String(), Tags: []string{"env:test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+
+	recorder.MessageReceived("orders")
+	recorder.ObserveDuration("orders", 2500*time.Microsecond)
+
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	for _, expected := range []string{
+		"sqsworker.messages.received:1|d|#queue:orders,env:test",
+		"sqsworker.processing.duration:2.5|d|#queue:orders,env:test",
+	} {
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("expected %q, got %v", expected, err)
+		}
+		if line := string(buf[:n]); line != expected {
+			t.Errorf("expected %q, got %q", expected, line)
+		}
+	}
+}
+
+func TestRecorderEscaping(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	recorder, err := NewRecorder(Config{Addr: server.LocalAddr().String(), Prefix: "my:app", Tags: []string{"team:a|b,c#d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+
+	recorder.MessageReceived("orders|x,y")
+
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := server.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line, expected := string(buf[:n]), "my_app.messages.received:1|d|#queue:orders_x_y,team:a_b_c_d"; line != expected {
+		t.Errorf("expected %q, got %q", expected, line)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	// a producer's span, passed along with the message as Datadog's tracers do
+	producer := tracer.StartSpan("sqs.send")
+	carrier := tracer.TextMapCarrier{}
+	if err := tracer.Inject(producer.Context(), carrier); err != nil {
+		t.Fatal(err)
+	}
+	producer.Finish()
+	traceContext, _ := json.Marshal(carrier)
+	value := string(traceContext)
+
+	msg := events.SQSMessage{
+		MessageId:      "1",
+		EventSourceARN: testQueueARN,
+		MessageAttributes: map[string]events.SQSMessageAttribute{
+			TraceContextAttribute: {DataType: "String", StringValue: &value},
+		},
+	}
+
+	processor := Trace("orders-worker")(func(ctx context.Context, msg events.SQSMessage) error {
+		if _, ok := tracer.SpanFromContext(ctx); !ok {
+			t.Error("expected the processor's context to hold the span")
+		}
+		return errors.New("failed")
+	})
+	if err := processor(context.Background(), msg); err == nil {
+		t.Error("expected the processor's error to be returned")
+	}
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected the producer's span and the message's, got %d", len(spans))
+	}
+	span := spans[1]
+	if span.OperationName() != "sqs.process" || span.Tag("resource.name") != "orders" || span.Tag("service.name") != "orders-worker" {
+		t.Errorf("expected a span for the queue, got %v", span.Tags())
+	}
+	if span.ParentID() != producer.Context().SpanID() || span.TraceID() != spans[0].TraceID() {
+		t.Errorf("expected the span to continue the producer's trace")
+	}
+	if span.Tag("error.message") != "failed" {
+		t.Errorf("expected the span to be marked with the error, got %v", span.Tags())
+	}
+}
+
+func TestTraceWithoutContext(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	processor := Trace("")(func(ctx context.Context, msg events.SQSMessage) error { return nil })
+	processor(context.Background(), events.SQSMessage{MessageId: "1", EventSourceARN: testQueueARN})
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 1 || spans[0].ParentID() != 0 {
+		t.Errorf("expected a new trace to be started, got %v", spans)
+	}
+}
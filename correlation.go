@@ -0,0 +1,77 @@
+package sqsworker
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// CorrelationIDAttribute is the message attribute correlation IDs are read from and
+// propagated with by default.
+const CorrelationIDAttribute = "CorrelationId"
+
+// CorrelationIDSource finds the correlation ID of a message, returning an empty
+// string if it doesn't have one.
+type CorrelationIDSource func(msg events.SQSMessage) string
+
+// CorrelationIDFromAttribute reads correlation IDs from the message attribute with
+// the given name.
+func CorrelationIDFromAttribute(name string) CorrelationIDSource {
+	return CorrelationIDSource(attributeString(name))
+}
+
+// CorrelationIDFromBody reads correlation IDs from the field of a JSON body at the
+// given path, such as "$.meta.requestId".
+func CorrelationIDFromBody(path string) CorrelationIDSource {
+	return CorrelationIDSource(bodyString(path))
+}
+
+// CorrelationID returns the correlation ID of the message being processed, if the
+// handler was created with WithCorrelationID.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
+
+// correlation is how a handler finds and propagates correlation IDs.
+type correlation struct {
+	attribute string
+	source    CorrelationIDSource
+}
+
+// withCorrelationID adds the message's correlation ID to the context, falling back to
+// the message ID so that a chain of workers can be followed from its first message.
+func (s *Handler) withCorrelationID(ctx context.Context, msg events.SQSMessage) context.Context {
+	if s.correlation.source == nil {
+		return ctx
+	}
+
+	id := s.correlation.source(msg)
+	if id == "" {
+		id = msg.MessageId
+	}
+
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// correlateSQS adds the correlation ID to the attributes of a message being sent on,
+// if there's room for it.
+func (s *Handler) correlateSQS(ctx context.Context, attrs map[string]*sqs.MessageAttributeValue) {
+	id := CorrelationID(ctx)
+	if id == "" || len(attrs) >= maxMessageAttributes {
+		return
+	}
+	attrs[s.correlation.attribute] = stringAttribute(id)
+}
+
+// correlateSNS adds the correlation ID to the attributes of a message being published.
+func (s *Handler) correlateSNS(ctx context.Context, attrs map[string]*sns.MessageAttributeValue) {
+	id := CorrelationID(ctx)
+	if id == "" || len(attrs) >= maxMessageAttributes {
+		return
+	}
+	dataType := "String"
+	attrs[s.correlation.attribute] = &sns.MessageAttributeValue{DataType: &dataType, StringValue: &id}
+}
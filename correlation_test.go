@@ -0,0 +1,81 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestWithCorrelationID(t *testing.T) {
+	var buf bytes.Buffer
+	client, snsClient := &fakeSQSClient{}, &fakeSNSClient{}
+
+	seen := map[string]string{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		seen[msg.MessageId] = CorrelationID(ctx)
+		SetOutput(ctx, "done")
+		if msg.MessageId == "bad" {
+			return Permanent(errors.New("bad payload"))
+		}
+		return nil
+	},
+		WithCorrelationID("", nil),
+		WithLogger(log.New(&buf, "", 0)),
+		WithForwardQueue("https://sqs.us-west-2.amazonaws.com/123456/next"),
+		WithPublishTopic(snsClient, "arn:aws:sns:us-west-2:123456:results"),
+		WithMaxConcurrency(1),
+	)
+
+	tagged := testMessage("tagged")
+	tagged.MessageAttributes = map[string]events.SQSMessageAttribute{
+		CorrelationIDAttribute: {DataType: "String", StringValue: aws.String("request-1")},
+	}
+	bad := testMessage("bad")
+	bad.MessageAttributes = tagged.MessageAttributes
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{tagged})
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("untagged"), bad})
+
+	if seen["tagged"] != "request-1" || seen["untagged"] != "untagged" {
+		t.Errorf("unexpected correlation IDs %v", seen)
+	}
+
+	for _, input := range client.sent {
+		if attr := input.MessageAttributes[CorrelationIDAttribute]; attr == nil || *attr.StringValue == "" {
+			t.Errorf("expected the correlation ID to be forwarded, got %v", input.MessageAttributes)
+		}
+	}
+	published := snsClient.published[0].MessageAttributes[CorrelationIDAttribute]
+	if published == nil || *published.StringValue != "request-1" {
+		t.Errorf("expected the correlation ID to be published, got %v", snsClient.published[0].MessageAttributes)
+	}
+
+	if !strings.Contains(buf.String(), "message bad failed permanently: bad payload correlationId=request-1") {
+		t.Errorf("expected the correlation ID in the logs, got %q", buf.String())
+	}
+}
+
+func TestCorrelationIDFromBody(t *testing.T) {
+	var id string
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		id = CorrelationID(ctx)
+		return nil
+	}, WithCorrelationID("RequestId", CorrelationIDFromBody("$.meta.requestId")))
+
+	msg := testMessage("1")
+	msg.Body = `{"meta": {"requestId": "abc"}}`
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{msg})
+
+	if id != "abc" {
+		t.Errorf("expected correlation ID abc, got %q", id)
+	}
+	if CorrelationID(context.Background()) != "" {
+		t.Error("expected no correlation ID outside of a handler")
+	}
+}
@@ -0,0 +1,165 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const (
+	// FailureAttribute is the message attribute added to dead-lettered messages that
+	// holds the FailureDetails as JSON.
+	FailureAttribute = "SQSWorkerFailure"
+
+	// maxMessageAttributes is the number of message attributes SQS allows per message.
+	maxMessageAttributes = 10
+)
+
+// FailureDetails describes why and when a message was dead-lettered.
+type FailureDetails struct {
+	Error                 string    `json:"error"`
+	MessageID             string    `json:"messageId"`
+	SourceQueueARN        string    `json:"sourceQueueArn"`
+	ReceiveCount          int       `json:"receiveCount"`
+	SentTimestamp         time.Time `json:"sentTimestamp"`
+	FirstReceiveTimestamp time.Time `json:"firstReceiveTimestamp"`
+	FailedAt              time.Time `json:"failedAt"`
+}
+
+// sendToDeadLetter forwards a permanently failed message to the dead-letter queue.
+// A nil error means the message has been dealt with and can be deleted from its
+// source queue.  When no dead-letter queue is configured the message is simply
+// dropped.
+func (s *Handler) sendToDeadLetter(ctx context.Context, msg events.SQSMessage, cause error) error {
+	if s.deadLetter == "" {
+		return nil
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:          &s.deadLetter,
+		MessageBody:       &msg.Body,
+		MessageAttributes: toMessageAttributes(msg.MessageAttributes),
+	}
+
+	// attach the failure details if there's room left for another attribute
+	if len(input.MessageAttributes) < maxMessageAttributes {
+		details, err := json.Marshal(newFailureDetails(msg, cause))
+		if err != nil {
+			return err
+		}
+		input.MessageAttributes[FailureAttribute] = stringAttribute(string(details))
+	} else {
+		s.logMessage(ctx, "no room to attach failure details to dead-lettered message %s", msg.MessageId)
+	}
+
+	setFIFOFields(input, msg)
+
+	if err := s.sendMessage(input); err != nil {
+		return fmt.Errorf("failed to dead-letter message %s: %w", msg.MessageId, err)
+	}
+
+	return nil
+}
+
+// errCannotSend is returned for messages that need sending when the handler's client
+// doesn't implement PartialSQSSender.
+var errCannotSend = errors.New("SQS client can't send messages")
+
+// sendMessage sends a message with the handler's client, if it's able to.
+func (s *Handler) sendMessage(input *sqs.SendMessageInput) error {
+	sender, ok := s.sqsClient.(PartialSQSSender)
+	if !ok {
+		return errCannotSend
+	}
+
+	_, err := sender.SendMessage(input)
+	return err
+}
+
+// newFailureDetails collects the failure details for a message from its system attributes.
+func newFailureDetails(msg events.SQSMessage, cause error) FailureDetails {
+	return FailureDetails{
+		Error:                 cause.Error(),
+		MessageID:             msg.MessageId,
+		SourceQueueARN:        msg.EventSourceARN,
+		ReceiveCount:          receiveCount(msg),
+		SentTimestamp:         millisToTime(msg.Attributes["SentTimestamp"]),
+		FirstReceiveTimestamp: millisToTime(msg.Attributes["ApproximateFirstReceiveTimestamp"]),
+		FailedAt:              time.Now().UTC(),
+	}
+}
+
+// toMessageAttributes converts the attributes of a received message into the form
+// needed to send them on to another queue.  The attributes are copied in name order
+// so that the same ones are kept if there are too many to send.
+func toMessageAttributes(attrs map[string]events.SQSMessageAttribute) map[string]*sqs.MessageAttributeValue {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make(map[string]*sqs.MessageAttributeValue, len(attrs))
+	for _, name := range names {
+		if len(result) == maxMessageAttributes {
+			break
+		}
+
+		attr := attrs[name]
+		dataType := attr.DataType
+		value := &sqs.MessageAttributeValue{DataType: &dataType}
+		if attr.StringValue != nil {
+			str := *attr.StringValue
+			value.StringValue = &str
+		}
+		if attr.BinaryValue != nil {
+			value.BinaryValue = attr.BinaryValue
+		}
+		result[name] = value
+	}
+
+	return result
+}
+
+// setFIFOFields gives a message being sent on to a FIFO queue the group and
+// deduplication ID that FIFO queues need.
+func setFIFOFields(input *sqs.SendMessageInput, msg events.SQSMessage) {
+	if strings.HasSuffix(*input.QueueUrl, ".fifo") {
+		input.MessageGroupId, input.MessageDeduplicationId = fifoIDs(msg)
+	}
+}
+
+// fifoIDs returns the group and deduplication ID to send a message on to a FIFO queue
+// or topic with.  The message keeps its original group, if it had one, and is
+// deduplicated by the ID it was received with.
+func fifoIDs(msg events.SQSMessage) (group, deduplicationID *string) {
+	groupID := msg.Attributes["MessageGroupId"]
+	if groupID == "" {
+		groupID = msg.MessageId
+	}
+	messageID := msg.MessageId
+	return &groupID, &messageID
+}
+
+// stringAttribute creates a message attribute holding a string value.
+func stringAttribute(value string) *sqs.MessageAttributeValue {
+	dataType := "String"
+	return &sqs.MessageAttributeValue{DataType: &dataType, StringValue: &value}
+}
+
+// millisToTime converts an epoch milliseconds attribute into a time.
+func millisToTime(value string) time.Time {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms).UTC()
+}
@@ -0,0 +1,94 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const testDeadLetterURL = "https://sqs.us-west-2.amazonaws.com/123456/my_dlq"
+
+func TestWithDeadLetterQueue(t *testing.T) {
+	client := &fakeSQSClient{}
+	processor := func(ctx context.Context, msg events.SQSMessage) error {
+		return Permanent(errors.New("bad payload"))
+	}
+
+	kind := "order"
+	msg := testMessage("1")
+	msg.Body = `{"id":1}`
+	msg.Attributes = map[string]string{"ApproximateReceiveCount": "3", "SentTimestamp": "1500000000000"}
+	msg.MessageAttributes = map[string]events.SQSMessageAttribute{
+		"type": {DataType: "String", StringValue: &kind},
+	}
+
+	handler := NewHandler(client, processor, WithDeadLetterQueue(testDeadLetterURL))
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{msg})
+
+	if err != nil || completed != 1 {
+		t.Fatalf("expected the message to be closed, got %d completed and error %v", completed, err)
+	}
+	if len(client.sent) != 1 || len(client.deleted) != 1 {
+		t.Fatalf("expected the message to be forwarded and deleted, got %d sent and %d deleted", len(client.sent), len(client.deleted))
+	}
+
+	sent := client.sent[0]
+	if *sent.QueueUrl != testDeadLetterURL || *sent.MessageBody != msg.Body {
+		t.Errorf("expected the original body to be sent to the DLQ, got %v", sent)
+	}
+	if *sent.MessageAttributes["type"].StringValue != kind {
+		t.Error("expected the original message attributes to be kept")
+	}
+
+	var details FailureDetails
+	if err := json.Unmarshal([]byte(*sent.MessageAttributes[FailureAttribute].StringValue), &details); err != nil {
+		t.Fatal(err)
+	}
+	if details.Error != "bad payload" || details.ReceiveCount != 3 || details.MessageID != "1" || details.SentTimestamp.IsZero() {
+		t.Errorf("unexpected failure details %+v", details)
+	}
+}
+
+func TestWithDeadLetterQueueSendFailure(t *testing.T) {
+	client := &fakeSQSClient{sendErr: errors.New("unavailable")}
+	processor := func(ctx context.Context, msg events.SQSMessage) error {
+		return Permanent(errors.New("bad payload"))
+	}
+
+	handler := NewHandler(client, processor, WithDeadLetterQueue(testDeadLetterURL))
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if err == nil || completed != 0 || len(client.deleted) != 0 {
+		t.Errorf("expected the message to stay on the queue when the DLQ is unavailable")
+	}
+}
+
+func TestWithDeadLetterQueueWithoutSender(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(minimalSQSClient{client: client}, func(ctx context.Context, msg events.SQSMessage) error {
+		return Permanent(errors.New("bad payload"))
+	}, WithDeadLetterQueue(testDeadLetterURL), WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+	if !errors.Is(err, errCannotSend) || completed != 0 || len(client.deleted) != 0 {
+		t.Errorf("expected the message to stay on the queue when the client can't send, got %d: %v", completed, err)
+	}
+}
+
+func TestWithDeadLetterQueueFIFO(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return Permanent(errors.New("bad payload"))
+	}, WithDeadLetterQueue(testDeadLetterURL+".fifo"))
+
+	msg := testMessage("1")
+	msg.Attributes = map[string]string{"MessageGroupId": "group"}
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{msg})
+
+	if len(client.sent) != 1 || *client.sent[0].MessageGroupId != "group" || *client.sent[0].MessageDeduplicationId != "1" {
+		t.Error("expected the group and deduplication IDs to be set for a FIFO DLQ")
+	}
+}
@@ -0,0 +1,49 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDeadline is reported for messages that weren't processed because the invocation
+// was too close to its deadline.
+var ErrDeadline = errors.New("not enough time left in the invocation to process message")
+
+// withDeadlineMargin derives a context that's cancelled the deadline margin before the
+// invocation's own deadline.
+func (s *Handler) withDeadlineMargin(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || s.margin <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithDeadline(ctx, deadline.Add(-s.margin))
+}
+
+// withInvocation keeps the invocation's own context, before the deadline margin or
+// failing fast can cancel it, for closing messages with afterProcessing.
+func withInvocation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, invocationKey, ctx)
+}
+
+// afterProcessing returns the context to close a message with once it's been
+// processed.  It has the message's values, but is only cancelled with the invocation
+// itself, so that the deadline margin stops processors without losing the results of
+// the messages that finished.
+func afterProcessing(ctx context.Context) context.Context {
+	invocation, ok := ctx.Value(invocationKey).(context.Context)
+	if !ok {
+		return ctx
+	}
+	return invocationContext{Context: invocation, values: ctx}
+}
+
+// invocationContext is the invocation's context with the values of a message's.
+type invocationContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c invocationContext) Value(key any) any {
+	return c.values.Value(key)
+}
@@ -0,0 +1,83 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithDeadlineMargin(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "slow" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}, WithDeadlineMargin(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second+50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	outcomes := handler.processBatch(ctx, []events.SQSMessage{
+		testMessage("fast"),
+		testMessage("slow"),
+	})
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected processing to stop at the margin, took %v", elapsed)
+	}
+
+	errs := map[string]error{}
+	for _, res := range outcomes {
+		errs[res.msg.MessageId] = res.err
+	}
+	if errs["fast"] != nil || !errors.Is(errs["slow"], context.DeadlineExceeded) {
+		t.Errorf("unexpected outcomes %v", errs)
+	}
+	if len(client.deleted) != 1 {
+		t.Errorf("expected only the fast message to be deleted, got %v", client.deleted)
+	}
+}
+
+func TestHandleMessageAfterDeadline(t *testing.T) {
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		t.Error("expected the processor not to be called")
+		return nil
+	})
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	if res := handler.handleMessage(ctx, testMessage("1")); !errors.Is(res.err, ErrDeadline) {
+		t.Errorf("expected ErrDeadline, got %v", res.err)
+	}
+}
+
+func TestWithDeadlineMarginClosesProcessedMessages(t *testing.T) {
+	client, snsClient := &fakeSQSClient{}, &fakeSNSClient{}
+	store := &memoryIdempotencyStore{keys: map[string]time.Duration{}}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		// finish just as the margin is reached
+		<-ctx.Done()
+		SetOutput(ctx, "done")
+		return nil
+	}, WithDeadlineMargin(time.Second), WithIdempotencyStore(store, time.Hour, nil),
+		WithPublishTopic(snsClient, "arn:aws:sns:us-west-2:123456:results"), WithLogger(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second+50*time.Millisecond)
+	defer cancel()
+
+	completed, err := handler.ProcessMessages(ctx, testMessages(1))
+	if completed != 1 || err != nil {
+		t.Errorf("expected the processed message to be closed past the margin, got %d: %v", completed, err)
+	}
+	if len(snsClient.published) != 1 || len(store.keys) != 1 || len(client.deleted) != 1 {
+		t.Errorf("expected the output to be published and the message marked and deleted, got %d, %v and %v",
+			len(snsClient.published), store.keys, client.deleted)
+	}
+}
@@ -0,0 +1,130 @@
+package sqsworker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ContentEncodingAttribute is the message attribute Decompress reads to find out how a
+// body was encoded.  Its value lists the encodings in the order they were applied, such
+// as "gzip" or "zstd, base64".
+const ContentEncodingAttribute = "Content-Encoding"
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderOnce sync.Once
+)
+
+// Decompress is a Middleware that hands the processor decompressed bodies.  The
+// encoding is taken from the Content-Encoding message attribute, supporting gzip, zstd
+// and base64.  Because SQS bodies must be text, compressed bodies are expected to be
+// base64 encoded whether or not base64 is listed.  Without the attribute, bodies that
+// are base64 encoded gzip or zstd data are detected by their magic bytes, and anything
+// else is passed through untouched.  Bodies that can't be decoded fail permanently.
+func Decompress(next MessageProcessor) MessageProcessor {
+	return func(ctx context.Context, msg events.SQSMessage) error {
+		body, err := decodeBody(msg)
+		if err != nil {
+			return Permanent(fmt.Errorf("failed to decode message %s: %w", msg.MessageId, err))
+		}
+
+		msg.Body = string(body)
+		return next(ctx, msg)
+	}
+}
+
+// decodeBody undoes the encodings applied to a message body.
+func decodeBody(msg events.SQSMessage) ([]byte, error) {
+	data := []byte(msg.Body)
+
+	encoding, ok := attributeValue(msg, ContentEncodingAttribute)
+	if !ok {
+		return sniffBody(data)
+	}
+
+	// undo the encodings in the reverse of the order they were applied
+	encodings := strings.Split(encoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+
+		switch name := strings.ToLower(strings.TrimSpace(encodings[i])); name {
+		case "", "identity":
+		case "base64":
+			data, err = decodeBase64(data)
+		case "gzip", "zstd":
+			magic := gzipMagic
+			if name == "zstd" {
+				magic = zstdMagic
+			}
+			if !bytes.HasPrefix(data, magic) {
+				if data, err = decodeBase64(data); err != nil {
+					break
+				}
+			}
+			data, err = decompress(name, data)
+		default:
+			err = fmt.Errorf("unsupported content encoding %q", name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// sniffBody decompresses bodies that look like base64 encoded gzip or zstd data.
+func sniffBody(data []byte) ([]byte, error) {
+	decoded, err := decodeBase64(data)
+	if err != nil {
+		return data, nil
+	}
+
+	switch {
+	case bytes.HasPrefix(decoded, gzipMagic):
+		return decompress("gzip", decoded)
+	case bytes.HasPrefix(decoded, zstdMagic):
+		return decompress("zstd", decoded)
+	default:
+		return data, nil
+	}
+}
+
+// decompress decompresses data with the named algorithm.
+func decompress(algorithm string, data []byte) ([]byte, error) {
+	if algorithm == "zstd" {
+		zstdDecoderOnce.Do(func() {
+			zstdDecoder, _ = zstd.NewReader(nil)
+		})
+		return zstdDecoder.DecodeAll(data, nil)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// decodeBase64 decodes standard base64, with or without padding.
+func decodeBase64(data []byte) ([]byte, error) {
+	trimmed := bytes.TrimRight(bytes.TrimSpace(data), "=")
+	decoded := make([]byte, base64.RawStdEncoding.DecodedLen(len(trimmed)))
+	n, err := base64.RawStdEncoding.Decode(decoded, trimmed)
+	return decoded[:n], err
+}
@@ -0,0 +1,87 @@
+package sqsworker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipped(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write([]byte(data))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdCompressed(t *testing.T, data string) []byte {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+	return encoder.EncodeAll([]byte(data), nil)
+}
+
+// encodedMessage creates a message with the given body and Content-Encoding, if any.
+func encodedMessage(body, encoding string) events.SQSMessage {
+	msg := testMessage("1")
+	msg.Body = body
+	if encoding != "" {
+		msg.MessageAttributes = map[string]events.SQSMessageAttribute{
+			ContentEncodingAttribute: {DataType: "String", StringValue: &encoding},
+		}
+	}
+	return msg
+}
+
+func TestDecompress(t *testing.T) {
+	const payload = `{"id":1}`
+	b64 := base64.StdEncoding.EncodeToString
+
+	cases := []events.SQSMessage{
+		encodedMessage(b64(gzipped(t, payload)), "gzip"),
+		encodedMessage(b64(gzipped(t, payload)), "gzip, base64"),
+		encodedMessage(b64(zstdCompressed(t, payload)), "zstd"),
+		encodedMessage(b64([]byte(payload)), "base64"),
+		encodedMessage(b64(gzipped(t, payload)), ""),
+		encodedMessage(b64(zstdCompressed(t, payload)), ""),
+		encodedMessage(payload, ""),
+		encodedMessage(payload, "identity"),
+	}
+
+	for i, msg := range cases {
+		var body string
+		err := Decompress(func(ctx context.Context, msg events.SQSMessage) error {
+			body = msg.Body
+			return nil
+		})(context.Background(), msg)
+
+		if err != nil || body != payload {
+			t.Errorf("case %d: expected %s, got %q and %v", i, payload, body, err)
+		}
+	}
+}
+
+func TestDecompressInvalid(t *testing.T) {
+	processor := Decompress(func(ctx context.Context, msg events.SQSMessage) error {
+		t.Error("expected the processor not to be called")
+		return nil
+	})
+
+	for _, msg := range []events.SQSMessage{
+		encodedMessage("not gzip", "gzip"),
+		encodedMessage("abc", "brotli"),
+	} {
+		if err := processor(context.Background(), msg); classifyError(nil, err) != ClassPermanent {
+			t.Errorf("expected an undecodable body to fail permanently, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// Decrypter decrypts the body of a message that was encrypted by its producer.
+type Decrypter interface {
+	Decrypt(ctx context.Context, ciphertext []byte, msg events.SQSMessage) ([]byte, error)
+}
+
+// DecrypterFunc allows an ordinary function to be used as a Decrypter, such as one
+// wrapping an AWS Encryption SDK keyring.
+type DecrypterFunc func(ctx context.Context, ciphertext []byte, msg events.SQSMessage) ([]byte, error)
+
+// Decrypt implements the Decrypter interface.
+func (f DecrypterFunc) Decrypt(ctx context.Context, ciphertext []byte, msg events.SQSMessage) ([]byte, error) {
+	return f(ctx, ciphertext, msg)
+}
+
+// PartialKMSClient is a partial KMS client that can decrypt data.
+type PartialKMSClient interface {
+	DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error)
+}
+
+// KMSDecrypter is a Decrypter for bodies encrypted directly with a KMS key.
+type KMSDecrypter struct {
+	Client PartialKMSClient
+	// KeyID is the key the body must have been encrypted with.  Leaving it empty
+	// allows any symmetric key the function has access to.
+	KeyID string
+	// EncryptionContext is the encryption context the body was encrypted with, if any.
+	EncryptionContext map[string]string
+}
+
+// NewKMSDecrypter creates a KMSDecrypter that only accepts bodies encrypted with the
+// given key.
+func NewKMSDecrypter(client PartialKMSClient, keyID string) *KMSDecrypter {
+	return &KMSDecrypter{Client: client, KeyID: keyID}
+}
+
+// Decrypt implements the Decrypter interface.  Errors that are likely to succeed if
+// tried again, such as throttling, are marked as transient.
+func (d *KMSDecrypter) Decrypt(ctx context.Context, ciphertext []byte, msg events.SQSMessage) ([]byte, error) {
+	input := &kms.DecryptInput{CiphertextBlob: ciphertext}
+	if d.KeyID != "" {
+		input.KeyId = aws.String(d.KeyID)
+	}
+	if len(d.EncryptionContext) > 0 {
+		input.EncryptionContext = aws.StringMap(d.EncryptionContext)
+	}
+
+	out, err := d.Client.DecryptWithContext(ctx, input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case "ThrottlingException", kms.ErrCodeInternalException, kms.ErrCodeDependencyTimeoutException:
+				return nil, Transient(err)
+			}
+		}
+		return nil, err
+	}
+
+	return out.Plaintext, nil
+}
+
+// Decrypt creates a Middleware that decrypts base64 encoded message bodies before the
+// processor sees them, so that plaintext stays out of processor code and logs until
+// it's needed.  Messages that can't be decrypted fail permanently, unless the
+// decrypter marks the error as transient.
+func Decrypt(decrypter Decrypter) Middleware {
+	return func(next MessageProcessor) MessageProcessor {
+		return func(ctx context.Context, msg events.SQSMessage) error {
+			ciphertext, err := decodeBase64([]byte(msg.Body))
+			if err != nil {
+				return Permanent(fmt.Errorf("failed to decode ciphertext of message %s: %w", msg.MessageId, err))
+			}
+
+			plaintext, err := decrypter.Decrypt(ctx, ciphertext, msg)
+			if err != nil {
+				return permanentByDefault(fmt.Errorf("failed to decrypt message %s: %w", msg.MessageId, err))
+			}
+
+			msg.Body = string(plaintext)
+			return next(ctx, msg)
+		}
+	}
+}
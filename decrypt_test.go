@@ -0,0 +1,82 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// fakeKMSClient "decrypts" by reversing the ciphertext, failing with err if set.
+type fakeKMSClient struct {
+	input *kms.DecryptInput
+	err   error
+}
+
+func (f *fakeKMSClient) DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error) {
+	f.input = input
+	if f.err != nil {
+		return nil, f.err
+	}
+	plaintext := make([]byte, len(input.CiphertextBlob))
+	for i, b := range input.CiphertextBlob {
+		plaintext[len(plaintext)-1-i] = b
+	}
+	return &kms.DecryptOutput{Plaintext: plaintext}, nil
+}
+
+func TestDecryptKMS(t *testing.T) {
+	client := &fakeKMSClient{}
+	var body string
+	processor := Decrypt(NewKMSDecrypter(client, "alias/orders"))(func(ctx context.Context, msg events.SQSMessage) error {
+		body = msg.Body
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = base64.StdEncoding.EncodeToString([]byte("terces"))
+	if err := processor(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if body != "secret" {
+		t.Errorf("expected the decrypted body, got %s", body)
+	}
+	if *client.input.KeyId != "alias/orders" {
+		t.Errorf("expected the key to be pinned, got %v", client.input.KeyId)
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	next := func(ctx context.Context, msg events.SQSMessage) error {
+		t.Error("expected the processor not to be called")
+		return nil
+	}
+
+	cases := []struct {
+		body     string
+		err      error
+		expected ErrorClass
+	}{
+		{"!!!", nil, ClassPermanent},
+		{"YWJj", awserr.New(kms.ErrCodeInvalidCiphertextException, "invalid", nil), ClassPermanent},
+		{"YWJj", awserr.New("ThrottlingException", "slow down", nil), ClassTransient},
+		{"YWJj", errors.New("unknown"), ClassPermanent},
+	}
+
+	for _, c := range cases {
+		processor := Decrypt(NewKMSDecrypter(&fakeKMSClient{err: c.err}, ""))(next)
+
+		msg := testMessage("1")
+		msg.Body = c.body
+		if err := processor(context.Background(), msg); classifyError(nil, err) != c.expected {
+			t.Errorf("expected %v to be %v, got %v", c.err, c.expected, classifyError(nil, err))
+		}
+	}
+}
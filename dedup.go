@@ -0,0 +1,68 @@
+package sqsworker
+
+import "github.com/aws/aws-lambda-go/events"
+
+// DedupKey returns the key used to detect duplicate messages within a batch.  Messages
+// with an empty key are never treated as duplicates.
+type DedupKey func(msg events.SQSMessage) string
+
+// DedupByMessageID treats messages with the same message ID as duplicates.
+func DedupByMessageID(msg events.SQSMessage) string {
+	return msg.MessageId
+}
+
+// DedupByDeduplicationID treats messages with the same MessageDeduplicationId as
+// duplicates, falling back to the message ID for messages that don't have one.
+func DedupByDeduplicationID(msg events.SQSMessage) string {
+	if id := msg.Attributes["MessageDeduplicationId"]; id != "" {
+		return id
+	}
+	return msg.MessageId
+}
+
+// deduplicate splits a batch into the first message seen for each key and the
+// duplicates of each of those, keyed by the receipt handle of the first message.
+func deduplicate(messages []events.SQSMessage, key DedupKey) ([]events.SQSMessage, map[string][]events.SQSMessage) {
+	unique := make([]events.SQSMessage, 0, len(messages))
+	duplicates := map[string][]events.SQSMessage{}
+	first := map[string]string{}
+
+	for _, msg := range messages {
+		k := key(msg)
+		if k == "" {
+			unique = append(unique, msg)
+			continue
+		}
+
+		if handle, ok := first[k]; ok {
+			duplicates[handle] = append(duplicates[handle], msg)
+			continue
+		}
+
+		first[k] = msg.ReceiptHandle
+		unique = append(unique, msg)
+	}
+
+	return unique, duplicates
+}
+
+// settleDuplicates gives the duplicates of a message the same outcome as the message
+// itself, deleting them if it completed.
+func (s *Handler) settleDuplicates(res outcome, dups []events.SQSMessage) []outcome {
+	outcomes := make([]outcome, 0, len(dups))
+
+	for _, dup := range dups {
+		switch {
+		case res.err != nil:
+			outcomes = append(outcomes, outcome{msg: dup, err: res.err})
+		case res.pending:
+			outcomes = append(outcomes, outcome{msg: dup, pending: true})
+		case res.retained:
+			outcomes = append(outcomes, outcome{msg: dup, retained: true})
+		default:
+			outcomes = append(outcomes, outcome{msg: dup, err: s.deleteMessage(dup)})
+		}
+	}
+
+	return outcomes
+}
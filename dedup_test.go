@@ -0,0 +1,84 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// duplicateOf creates a second delivery of a message.
+func duplicateOf(msg events.SQSMessage, n string) events.SQSMessage {
+	msg.ReceiptHandle += "-" + n
+	return msg
+}
+
+func TestWithDeduplication(t *testing.T) {
+	for _, batchDelete := range []bool{false, true} {
+		client := &fakeSQSClient{}
+		var mu sync.Mutex
+		calls := map[string]int{}
+
+		opts := []Option{WithDeduplication(DedupByMessageID)}
+		if batchDelete {
+			opts = append(opts, WithBatchDelete())
+		}
+
+		handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+			mu.Lock()
+			calls[msg.MessageId]++
+			mu.Unlock()
+			if msg.MessageId == "bad" {
+				return errors.New("failed")
+			}
+			return nil
+		}, opts...)
+
+		good, bad := testMessage("good"), testMessage("bad")
+		completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+			good, duplicateOf(good, "1"), bad, duplicateOf(good, "2"), duplicateOf(bad, "1"),
+		})
+
+		if err == nil || completed != 3 {
+			t.Errorf("expected the message and its duplicates to complete, got %d and %v", completed, err)
+		}
+		if calls["good"] != 1 || calls["bad"] != 1 {
+			t.Errorf("expected each message to be processed once, got %v", calls)
+		}
+		if len(client.deleted) != 3 {
+			t.Errorf("expected every delivery of the good message to be deleted, got %v", client.deleted)
+		}
+	}
+}
+
+func TestDedupByDeduplicationID(t *testing.T) {
+	a, b, c := testMessage("a"), testMessage("b"), testMessage("c")
+	a.Attributes = map[string]string{"MessageDeduplicationId": "same"}
+	b.Attributes = map[string]string{"MessageDeduplicationId": "same"}
+
+	unique, duplicates := deduplicate([]events.SQSMessage{a, b, c}, DedupByDeduplicationID)
+
+	if len(unique) != 2 || len(duplicates[a.ReceiptHandle]) != 1 {
+		t.Errorf("expected b to be a duplicate of a, got %v and %v", unique, duplicates)
+	}
+}
+
+func TestWithDeduplicationRetained(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithDeduplication(DedupByMessageID), WithDeletePolicy(DeleteNever), WithLogger(nil))
+
+	dup := testMessage("a")
+	dup.ReceiptHandle = "handle-a-again"
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("a"), dup})
+
+	if completed != 2 || err != nil {
+		t.Errorf("expected both messages to complete, got %d: %v", completed, err)
+	}
+	if len(client.deleted) != 0 {
+		t.Errorf("expected the duplicate to be left for Lambda too, got %v", client.deleted)
+	}
+}
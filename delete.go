@@ -0,0 +1,202 @@
+package sqsworker
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const (
+	// maxBatchDeleteEntries is the most entries SQS accepts in a single DeleteMessageBatch call.
+	maxBatchDeleteEntries = 10
+
+	// batchDeleteAttempts is the number of times entries that failed to delete are attempted.
+	batchDeleteAttempts = 3
+)
+
+// defaultDeleteBackoff is how batch deletes back off between attempts when the
+// handler's retry policy doesn't give a delay.
+var defaultDeleteBackoff = ExponentialBackoff{MaxRetries: batchDeleteAttempts - 1, BaseDelay: defaultRetryDelay}
+
+// deleteMessage removes a single message from its queue.
+func (s *Handler) deleteMessage(msg events.SQSMessage) error {
+	queueURL, err := s.queueURL(msg.EventSourceARN)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.sqsClient.DeleteMessage(&sqs.DeleteMessageInput{
+		ReceiptHandle: &msg.ReceiptHandle,
+		QueueUrl:      &queueURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.metrics.MessageDeleted(s.queueLabel(msg.EventSourceARN))
+	return nil
+}
+
+// deleteMessages removes messages from their queues using as few DeleteMessageBatch
+// calls as possible, or one at a time if the client can't delete in batches.  The
+// returned errors line up with the messages given, with a nil error for each message
+// that was deleted.
+func (s *Handler) deleteMessages(msgs []events.SQSMessage) []error {
+	errs := make([]error, len(msgs))
+
+	deleter, ok := s.sqsClient.(PartialSQSBatchDeleter)
+	if !ok {
+		for i, msg := range msgs {
+			if err := s.deleteMessage(msg); err != nil {
+				errs[i] = fmt.Errorf("failed to delete message %s: %w", msg.MessageId, err)
+				s.logger.Printf("%v", errs[i])
+			}
+		}
+		return errs
+	}
+
+	// group the messages by queue, keeping the order the queues were first seen in
+	var queues []string
+	byQueue := map[string][]int{}
+	for i, msg := range msgs {
+		queueURL, err := s.queueURL(msg.EventSourceARN)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if _, ok := byQueue[queueURL]; !ok {
+			queues = append(queues, queueURL)
+		}
+		byQueue[queueURL] = append(byQueue[queueURL], i)
+	}
+
+	for _, queueURL := range queues {
+		indexes := byQueue[queueURL]
+		for start := 0; start < len(indexes); start += maxBatchDeleteEntries {
+			end := start + maxBatchDeleteEntries
+			if end > len(indexes) {
+				end = len(indexes)
+			}
+			s.deleteBatch(deleter, queueURL, msgs, indexes[start:end], errs)
+		}
+	}
+
+	for i, err := range errs {
+		if err == nil {
+			s.metrics.MessageDeleted(s.queueLabel(msgs[i].EventSourceARN))
+		}
+	}
+
+	return errs
+}
+
+// deleteBatch deletes the messages at the given indexes with a DeleteMessageBatch call,
+// retrying any entries that fail through no fault of the request.  The outcome for each
+// message is recorded in errs.
+func (s *Handler) deleteBatch(deleter PartialSQSBatchDeleter, queueURL string, msgs []events.SQSMessage, indexes []int, errs []error) {
+	remaining := indexes
+
+	buf := s.deleteBuffers.Get().(*deleteBuffer)
+	defer s.deleteBuffers.Put(buf)
+
+	for attempt := 1; len(remaining) > 0 && attempt <= batchDeleteAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(s.deleteBackoff(attempt-1, errs[remaining[0]]))
+		}
+		entries := buf.fill(msgs, remaining)
+
+		out, err := deleter.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+			QueueUrl: &queueURL,
+			Entries:  entries,
+		})
+
+		// the whole request failed, so every entry is worth trying again
+		if err != nil {
+			for _, i := range remaining {
+				errs[i] = fmt.Errorf("failed to delete message %s: %w", msgs[i].MessageId, err)
+			}
+			continue
+		}
+
+		for _, i := range remaining {
+			errs[i] = nil
+		}
+
+		var retry []int
+		for _, failed := range out.Failed {
+			j, err := strconv.Atoi(aws.StringValue(failed.Id))
+			if err != nil || j < 0 || j >= len(entries) {
+				continue
+			}
+			i := remaining[j]
+
+			errs[i] = fmt.Errorf("failed to delete message %s: %s: %s",
+				msgs[i].MessageId, aws.StringValue(failed.Code), aws.StringValue(failed.Message))
+
+			// sender faults, such as an expired receipt handle, won't succeed on a retry
+			if !aws.BoolValue(failed.SenderFault) {
+				retry = append(retry, i)
+			}
+		}
+		remaining = retry
+	}
+
+	for _, i := range indexes {
+		if errs[i] != nil {
+			s.logger.Printf("%v", errs[i])
+		}
+	}
+}
+
+// deleteBackoff is how long to wait before the given retry of a batch delete, using the
+// handler's retry policy's delay if it gives one.
+func (s *Handler) deleteBackoff(retry int, err error) time.Duration {
+	if s.retry != nil {
+		if delay, ok := s.retry.Backoff(retry, err); ok {
+			return delay
+		}
+	}
+
+	delay, _ := defaultDeleteBackoff.Backoff(retry, err)
+	return delay
+}
+
+// batchEntryIDs are the IDs given to the entries of a DeleteMessageBatch call, by
+// position.
+var batchEntryIDs = func() (ids [maxBatchDeleteEntries]string) {
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	return ids
+}()
+
+// deleteBuffer holds the entries of a DeleteMessageBatch call, kept on the Handler to
+// be used again by later batches.
+type deleteBuffer struct {
+	entries [maxBatchDeleteEntries]sqs.DeleteMessageBatchRequestEntry
+	ptrs    [maxBatchDeleteEntries]*sqs.DeleteMessageBatchRequestEntry
+}
+
+func newDeleteBuffer() interface{} {
+	buf := &deleteBuffer{}
+	for i := range buf.entries {
+		buf.ptrs[i] = &buf.entries[i]
+	}
+	return buf
+}
+
+// fill sets up an entry for each of the messages at the given indexes, identified by
+// their position in indexes.
+func (b *deleteBuffer) fill(msgs []events.SQSMessage, indexes []int) []*sqs.DeleteMessageBatchRequestEntry {
+	for j, i := range indexes {
+		b.entries[j] = sqs.DeleteMessageBatchRequestEntry{
+			Id:            &batchEntryIDs[j],
+			ReceiptHandle: &msgs[i].ReceiptHandle,
+		}
+	}
+	return b.ptrs[:len(indexes)]
+}
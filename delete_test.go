@@ -0,0 +1,102 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithBatchDelete(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "bad" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithBatchDelete())
+
+	messages := []events.SQSMessage{testMessage("bad")}
+	for i := 0; i < 12; i++ {
+		messages = append(messages, testMessage(fmt.Sprint(i)))
+	}
+	other := testMessage("other")
+	other.EventSourceARN = "arn:aws:sqs:us-west-2:123456:other_queue"
+	messages = append(messages, other)
+
+	completed, err := handler.ProcessMessages(context.Background(), messages)
+
+	if err == nil || completed != 13 {
+		t.Errorf("expected 13 completed messages and an error, got %d and %v", completed, err)
+	}
+	if client.batches != 3 {
+		t.Errorf("expected 2 batches for the first queue and 1 for the other, got %d", client.batches)
+	}
+	if len(client.deleted) != 13 {
+		t.Errorf("expected 13 deleted messages, got %d", len(client.deleted))
+	}
+}
+
+func TestWithBatchDeleteRetriesFailures(t *testing.T) {
+	var buf bytes.Buffer
+	client := &fakeSQSClient{failDelete: map[string]int{"handle-1": 1, "handle-2": batchDeleteAttempts}}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithBatchDelete(), WithLogger(log.New(&buf, "", 0)))
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		testMessage("1"),
+		testMessage("2"),
+		testMessage("3"),
+	})
+
+	if err == nil || completed != 2 {
+		t.Errorf("expected the repeatedly failing delete to be reported, got %d completed and %v", completed, err)
+	}
+	if client.batches != batchDeleteAttempts {
+		t.Errorf("expected %d delete attempts, got %d", batchDeleteAttempts, client.batches)
+	}
+	if !strings.Contains(buf.String(), "failed to delete message 2") || strings.Contains(buf.String(), "message 1:") {
+		t.Errorf("expected only the delete that kept failing to be logged, got %q", buf.String())
+	}
+}
+
+func TestWithBatchDeleteBacksOff(t *testing.T) {
+	client := &fakeSQSClient{failBatches: 2}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithBatchDelete(), WithRetry(ExponentialBackoff{MaxRetries: 1, BaseDelay: 20 * time.Millisecond}), WithLogger(nil))
+
+	start := time.Now()
+	completed, err := handler.ProcessMessages(context.Background(), testMessages(2))
+	elapsed := time.Since(start)
+
+	if completed != 2 || err != nil || client.batches != batchDeleteAttempts {
+		t.Errorf("expected the failed requests to be retried, got %d completed in %d batches: %v", completed, client.batches, err)
+	}
+	// the retry policy gives the first delay, and the default backoff the second
+	if elapsed < 20*time.Millisecond+2*defaultRetryDelay {
+		t.Errorf("expected the retries to back off, took %v", elapsed)
+	}
+}
+
+func TestWithBatchDeleteWithoutBatchClient(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(minimalSQSClient{client: client}, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithBatchDelete(), WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), testMessages(3))
+	if completed != 3 || err != nil {
+		t.Errorf("expected every message to complete, got %d: %v", completed, err)
+	}
+	if len(client.deleted) != 3 || client.batches != 0 {
+		t.Errorf("expected the messages to be deleted one at a time, got %v in %d batches", client.deleted, client.batches)
+	}
+}
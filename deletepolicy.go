@@ -0,0 +1,49 @@
+package sqsworker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeletePolicy decides which messages the handler deletes from the queue itself.
+type DeletePolicy int
+
+const (
+	// DeleteOnSuccess deletes messages that complete, and ones that fail permanently
+	// once they've been dead-lettered, leaving the rest to be redelivered.  This is the
+	// default.
+	DeleteOnSuccess DeletePolicy = iota
+	// DeleteAlways deletes every message that's processed, even if it fails, for
+	// fire-and-forget queues where failed messages shouldn't be redelivered.
+	DeleteAlways
+	// DeleteNever doesn't delete any messages, leaving Lambda to delete them based on
+	// the invocation's result.  It's meant for use with HandleWithResponse, where each
+	// message that didn't complete is reported as a batch item failure.
+	DeleteNever
+)
+
+func (p DeletePolicy) String() string {
+	switch p {
+	case DeleteOnSuccess:
+		return "on-success"
+	case DeleteAlways:
+		return "always"
+	case DeleteNever:
+		return "never"
+	}
+	return fmt.Sprintf("DeletePolicy(%d)", int(p))
+}
+
+// ParseDeletePolicy parses the name of a delete policy: "on-success", "always" or
+// "never".
+func ParseDeletePolicy(name string) (DeletePolicy, error) {
+	switch strings.ReplaceAll(strings.ToLower(name), "_", "-") {
+	case "on-success":
+		return DeleteOnSuccess, nil
+	case "always":
+		return DeleteAlways, nil
+	case "never":
+		return DeleteNever, nil
+	}
+	return 0, fmt.Errorf("unknown delete policy %q", name)
+}
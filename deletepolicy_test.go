@@ -0,0 +1,82 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithDeletePolicy(t *testing.T) {
+	processor := func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "bad" {
+			return errors.New("try again")
+		}
+		return nil
+	}
+
+	cases := []struct {
+		policy    DeletePolicy
+		deleted   int
+		completed int
+	}{
+		{DeleteOnSuccess, 1, 1},
+		{DeleteAlways, 2, 2},
+		{DeleteNever, 0, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.policy.String(), func(t *testing.T) {
+			client := &fakeSQSClient{}
+			handler := NewHandler(client, processor, WithDeletePolicy(c.policy))
+
+			completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("good"), testMessage("bad")})
+			if completed != c.completed {
+				t.Errorf("expected %d completed messages, got %d", c.completed, completed)
+			}
+			if len(client.deleted) != c.deleted {
+				t.Errorf("expected %d deleted messages, got %v", c.deleted, client.deleted)
+			}
+		})
+	}
+}
+
+func TestDeleteNeverWithResponse(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "bad" {
+			return errors.New("try again")
+		}
+		return nil
+	}, WithDeletePolicy(DeleteNever), WithBatchDelete())
+
+	response, err := handler.HandleWithResponse(context.Background(), events.SQSEvent{
+		Records: []events.SQSMessage{testMessage("good"), testMessage("bad")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(response.BatchItemFailures) != 1 || response.BatchItemFailures[0].ItemIdentifier != "bad" {
+		t.Errorf("expected only the failed message to be reported, got %+v", response.BatchItemFailures)
+	}
+	if len(client.deleted) != 0 || client.batches != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", client.deleted)
+	}
+}
+
+func TestParseDeletePolicy(t *testing.T) {
+	for _, policy := range []DeletePolicy{DeleteOnSuccess, DeleteAlways, DeleteNever} {
+		parsed, err := ParseDeletePolicy(policy.String())
+		if err != nil || parsed != policy {
+			t.Errorf("expected %v, got %v: %v", policy, parsed, err)
+		}
+	}
+
+	if parsed, _ := ParseDeletePolicy("ON_SUCCESS"); parsed != DeleteOnSuccess {
+		t.Errorf("expected names to be case insensitive, got %v", parsed)
+	}
+	if _, err := ParseDeletePolicy("sometimes"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
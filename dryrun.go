@@ -0,0 +1,145 @@
+package sqsworker
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// dryRun configures a handler that doesn't change anything.
+type dryRun struct {
+	enabled        bool
+	skipProcessors bool
+}
+
+// applyDryRun swaps the handler's clients for ones that log what they would have done
+// instead of doing it.
+func (s *Handler) applyDryRun() {
+	if !s.dryRun.enabled {
+		return
+	}
+
+	s.sqsClient = dryRunSQSClient{logger: s.logger}
+	if s.publish.client != nil {
+		s.publish.client = dryRunSNSClient{logger: s.logger}
+	}
+	if s.tasks.client != nil {
+		s.tasks.client = dryRunSFNClient{logger: s.logger}
+	}
+	if s.idempotency.store != nil {
+		s.idempotency.store = dryRunIdempotencyStore{IdempotencyStore: s.idempotency.store, logger: s.logger}
+	}
+	if s.notifier != nil {
+		s.notifier = dryRunNotifier{logger: s.logger}
+	}
+	if s.archive != nil {
+		s.archive.client = dryRunS3Uploader{logger: s.logger}
+	}
+
+	if s.dryRun.skipProcessors {
+		s.process = func(ctx context.Context, msg events.SQSMessage) error {
+			s.logMessage(ctx, "dry run: would process message %s", msg.MessageId)
+			return nil
+		}
+		s.shadow = nil
+	}
+}
+
+// dryRunSQSClient is a PartialSQSClient, PartialSQSBatchDeleter and PartialSQSSender
+// that only logs the calls made to it.
+type dryRunSQSClient struct {
+	logger Logger
+}
+
+func (c dryRunSQSClient) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	c.logger.Printf("dry run: would delete message with receipt handle %s from %s",
+		aws.StringValue(input.ReceiptHandle), aws.StringValue(input.QueueUrl))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (c dryRunSQSClient) DeleteMessageBatch(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	out := &sqs.DeleteMessageBatchOutput{}
+	for _, entry := range input.Entries {
+		c.logger.Printf("dry run: would delete message with receipt handle %s from %s",
+			aws.StringValue(entry.ReceiptHandle), aws.StringValue(input.QueueUrl))
+		out.Successful = append(out.Successful, &sqs.DeleteMessageBatchResultEntry{Id: entry.Id})
+	}
+	return out, nil
+}
+
+func (c dryRunSQSClient) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	c.logger.Printf("dry run: would change visibility timeout of message with receipt handle %s to %ds",
+		aws.StringValue(input.ReceiptHandle), aws.Int64Value(input.VisibilityTimeout))
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (c dryRunSQSClient) SendMessage(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	c.logger.Printf("dry run: would send message to %s: %s", aws.StringValue(input.QueueUrl), aws.StringValue(input.MessageBody))
+	return &sqs.SendMessageOutput{}, nil
+}
+
+// dryRunSNSClient is a PartialSNSClient that only logs the messages published to it.
+type dryRunSNSClient struct {
+	logger Logger
+}
+
+func (c dryRunSNSClient) PublishWithContext(ctx aws.Context, input *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error) {
+	c.logger.Printf("dry run: would publish message to %s: %s", aws.StringValue(input.TopicArn), aws.StringValue(input.Message))
+	return &sns.PublishOutput{}, nil
+}
+
+// dryRunSFNClient is a PartialSFNClient that only logs the callbacks sent to it.
+type dryRunSFNClient struct {
+	logger Logger
+}
+
+func (c dryRunSFNClient) SendTaskSuccessWithContext(ctx aws.Context, input *sfn.SendTaskSuccessInput, opts ...request.Option) (*sfn.SendTaskSuccessOutput, error) {
+	c.logger.Printf("dry run: would send task success: %s", aws.StringValue(input.Output))
+	return &sfn.SendTaskSuccessOutput{}, nil
+}
+
+func (c dryRunSFNClient) SendTaskFailureWithContext(ctx aws.Context, input *sfn.SendTaskFailureInput, opts ...request.Option) (*sfn.SendTaskFailureOutput, error) {
+	c.logger.Printf("dry run: would send task failure: %s", aws.StringValue(input.Cause))
+	return &sfn.SendTaskFailureOutput{}, nil
+}
+
+// dryRunIdempotencyStore checks an IdempotencyStore as normal but doesn't mark
+// anything as processed, so that messages aren't skipped once the dry run is over.
+type dryRunIdempotencyStore struct {
+	IdempotencyStore
+	logger Logger
+}
+
+func (d dryRunIdempotencyStore) MarkProcessed(ctx context.Context, key string, ttl time.Duration) error {
+	d.logger.Printf("dry run: would mark %s as processed", key)
+	return nil
+}
+
+// dryRunS3Uploader is a PartialS3Uploader that only logs the objects uploaded to it.
+type dryRunS3Uploader struct {
+	logger Logger
+}
+
+func (u dryRunS3Uploader) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	u.logger.Printf("dry run: would archive message to s3://%s/%s", aws.StringValue(input.Bucket), aws.StringValue(input.Key))
+	return &s3.PutObjectOutput{}, nil
+}
+
+// dryRunNotifier is a FailureNotifier that only logs the failures it's told about.
+type dryRunNotifier struct {
+	logger Logger
+}
+
+func (n dryRunNotifier) NotifyFailures(ctx context.Context, failures []Failure) error {
+	for _, failure := range failures {
+		n.logger.Printf("dry run: would notify failure of message %s from %s: %s", failure.MessageID, failure.Queue, failure.Error)
+	}
+	return nil
+}
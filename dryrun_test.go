@@ -0,0 +1,88 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithDryRun(t *testing.T) {
+	var buf bytes.Buffer
+	client, snsClient := &fakeSQSClient{}, &fakeSNSClient{}
+	store := &memoryIdempotencyStore{keys: map[string]time.Duration{}}
+	notifier := &fakeNotifier{}
+	uploader := &fakeS3Uploader{}
+	captureDir := t.TempDir()
+
+	processed := 0
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		processed++
+		if msg.MessageId == "bad" {
+			return Permanent(errors.New("bad payload"))
+		}
+		SetOutput(ctx, "done")
+		return nil
+	},
+		WithDryRun(true),
+		WithLogger(log.New(&buf, "", 0)),
+		WithDeadLetterQueue("https://sqs.us-west-2.amazonaws.com/123456/dlq"),
+		WithPublishTopic(snsClient, "arn:aws:sns:us-west-2:123456:results"),
+		WithIdempotencyStore(store, 0, nil),
+		WithFailureNotifier(notifier),
+		WithArchive(uploader, ArchiveConfig{Bucket: "archive", Mode: ArchiveOutcomes}),
+		WithCapture(captureDir),
+		WithMaxConcurrency(1),
+	)
+
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("good"), testMessage("bad")})
+
+	if processed != 2 || completed != 2 {
+		t.Errorf("expected both messages to be processed, got %d processed and %d completed", processed, completed)
+	}
+	if len(client.deleted) != 0 || len(client.sent) != 0 || len(snsClient.published) != 0 {
+		t.Errorf("expected no changes, got deletes %v, sends %v and publishes %v", client.deleted, client.sent, snsClient.published)
+	}
+	if len(notifier.notifications) != 0 {
+		t.Errorf("expected no notifications, got %v", notifier.notifications)
+	}
+	if seen, _ := store.Seen(context.Background(), "good"); seen {
+		t.Error("expected the message not to be marked as processed")
+	}
+	if captured, _ := os.ReadDir(captureDir); len(uploader.objects) != 0 || len(captured) != 0 {
+		t.Errorf("expected nothing to be archived or captured, got %v and %v", uploader.objects, captured)
+	}
+
+	for _, expected := range []string{
+		"dry run: would delete message with receipt handle handle-good",
+		"dry run: would send message to https://sqs.us-west-2.amazonaws.com/123456/dlq",
+		"dry run: would publish message to arn:aws:sns:us-west-2:123456:results",
+		"dry run: would mark good as processed",
+		"dry run: would notify failure of message bad from my_queue_name: bad payload",
+		"dry run: would archive message to s3://archive/",
+		"dry run: would capture 2 message(s) to " + captureDir,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the logs to contain %q, got %q", expected, buf.String())
+		}
+	}
+}
+
+func TestWithDryRunSkipProcessors(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		t.Error("expected the processor not to be called")
+		return nil
+	}, WithDryRunSkipProcessors(), WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+	if completed != 1 || err != nil || len(client.deleted) != 0 {
+		t.Errorf("expected the message to be treated as completed without deleting it, got %d: %v", completed, err)
+	}
+}
@@ -0,0 +1,229 @@
+package sqsworker
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// The environment variables read by NewHandlerFromEnv.
+const (
+	EnvMaxConcurrency  = "SQSWORKER_MAX_CONCURRENCY"
+	EnvDeadLetterURL   = "SQSWORKER_DLQ_URL"
+	EnvMaxReceiveCount = "SQSWORKER_MAX_RECEIVE_COUNT"
+	EnvDeletePolicy    = "SQSWORKER_DELETE_POLICY"
+	EnvBatchDelete     = "SQSWORKER_BATCH_DELETE"
+	EnvEndpoint        = "SQSWORKER_ENDPOINT"
+	EnvFIFO            = "SQSWORKER_FIFO"
+	EnvMaxRetries      = "SQSWORKER_MAX_RETRIES"
+	EnvRetryDelay      = "SQSWORKER_RETRY_DELAY"
+	EnvDeadlineMargin  = "SQSWORKER_DEADLINE_MARGIN"
+	EnvRateLimit       = "SQSWORKER_RATE_LIMIT"
+	EnvRateBurst       = "SQSWORKER_RATE_BURST"
+	EnvDryRun          = "SQSWORKER_DRY_RUN"
+	EnvBatchResponse   = "SQSWORKER_BATCH_RESPONSE"
+	EnvMessageTimeout  = "SQSWORKER_MESSAGE_TIMEOUT"
+	EnvChunkSize       = "SQSWORKER_CHUNK_SIZE"
+)
+
+// endpointVariables are the environment variables the SQS endpoint is read from, in
+// order of preference, including the SDKs' own service-specific and global overrides.
+var endpointVariables = []string{EnvEndpoint, "AWS_ENDPOINT_URL_SQS", "AWS_ENDPOINT_URL"}
+
+// defaultRetryDelay is the delay before the first retry when SQSWORKER_MAX_RETRIES is
+// set without SQSWORKER_RETRY_DELAY.
+const defaultRetryDelay = 100 * time.Millisecond
+
+// NewHandlerFromEnv creates a Handler configured from SQSWORKER_* environment variables,
+// with an SQS client created from the ambient AWS configuration.  Any options given are
+// applied after the ones from the environment, so can override them.  An error is
+// returned if any of the variables can't be understood.
+//
+//	SQSWORKER_MAX_CONCURRENCY    WithMaxConcurrency
+//	SQSWORKER_DLQ_URL            WithDeadLetterQueue
+//	SQSWORKER_MAX_RECEIVE_COUNT  WithMaxReceiveCount
+//	SQSWORKER_DELETE_POLICY      WithDeletePolicy, as "on-success", "always" or "never"
+//	SQSWORKER_BATCH_DELETE       WithBatchDelete when true
+//	SQSWORKER_ENDPOINT           the SQS client's endpoint, and WithEndpoint, falling back
+//	                             to AWS_ENDPOINT_URL_SQS and then AWS_ENDPOINT_URL
+//	SQSWORKER_FIFO               WithFIFO when true
+//	SQSWORKER_MAX_RETRIES        WithRetry using ExponentialBackoff
+//	SQSWORKER_RETRY_DELAY        the ExponentialBackoff base delay, 100ms by default
+//	SQSWORKER_DEADLINE_MARGIN    WithDeadlineMargin
+//	SQSWORKER_RATE_LIMIT         WithRateLimit, in calls per second
+//	SQSWORKER_RATE_BURST         the WithRateLimit burst, 1 by default
+//	SQSWORKER_DRY_RUN            WithDryRun when true
+//	SQSWORKER_BATCH_RESPONSE     WithBatchResponse when true
+//	SQSWORKER_MESSAGE_TIMEOUT    WithMessageTimeout
+//	SQSWORKER_CHUNK_SIZE         WithChunkSize
+func NewHandlerFromEnv(processor MessageProcessor, opts ...Option) (*Handler, error) {
+	envOpts, err := optionsFromEnv(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	config := aws.NewConfig()
+	if endpoint, ok := EndpointFromEnv(); ok {
+		config = config.WithEndpoint(endpoint)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	return NewHandler(sqs.New(sess), processor, append(envOpts, opts...)...), nil
+}
+
+// EndpointFromEnv returns the SQS endpoint set in the environment, from the first of
+// SQSWORKER_ENDPOINT, AWS_ENDPOINT_URL_SQS and AWS_ENDPOINT_URL that's set.
+func EndpointFromEnv() (string, bool) {
+	return (&envReader{lookup: os.LookupEnv}).endpoint()
+}
+
+// optionsFromEnv reads the handler's options from the environment using lookup.
+func optionsFromEnv(lookup func(string) (string, bool)) ([]Option, error) {
+	env := envReader{lookup: lookup}
+	var opts []Option
+
+	if n, ok := env.int(EnvMaxConcurrency); ok {
+		opts = append(opts, WithMaxConcurrency(n))
+	}
+	if url, ok := env.string(EnvDeadLetterURL); ok {
+		opts = append(opts, WithDeadLetterQueue(url))
+	}
+	if n, ok := env.int(EnvMaxReceiveCount); ok {
+		opts = append(opts, WithMaxReceiveCount(n))
+	}
+	if policy, ok := env.string(EnvDeletePolicy); ok {
+		parsed, err := ParseDeletePolicy(policy)
+		if err != nil {
+			env.fail(EnvDeletePolicy, policy, err)
+		}
+		opts = append(opts, WithDeletePolicy(parsed))
+	}
+	if batch, ok := env.bool(EnvBatchDelete); ok && batch {
+		opts = append(opts, WithBatchDelete())
+	}
+	if endpoint, ok := env.endpoint(); ok {
+		opts = append(opts, WithEndpoint(endpoint))
+	}
+	if fifo, ok := env.bool(EnvFIFO); ok && fifo {
+		opts = append(opts, WithFIFO())
+	}
+	if retries, ok := env.int(EnvMaxRetries); ok {
+		delay, ok := env.duration(EnvRetryDelay)
+		if !ok {
+			delay = defaultRetryDelay
+		}
+		opts = append(opts, WithRetry(ExponentialBackoff{MaxRetries: retries, BaseDelay: delay}))
+	}
+	if margin, ok := env.duration(EnvDeadlineMargin); ok {
+		opts = append(opts, WithDeadlineMargin(margin))
+	}
+	if timeout, ok := env.duration(EnvMessageTimeout); ok {
+		opts = append(opts, WithMessageTimeout(timeout))
+	}
+	if n, ok := env.int(EnvChunkSize); ok {
+		opts = append(opts, WithChunkSize(n))
+	}
+	if rps, ok := env.float(EnvRateLimit); ok {
+		burst, _ := env.int(EnvRateBurst)
+		opts = append(opts, WithRateLimit(rps, burst))
+	}
+
+	if dryRun, ok := env.bool(EnvDryRun); ok {
+		opts = append(opts, WithDryRun(dryRun))
+	}
+	if batchResponse, ok := env.bool(EnvBatchResponse); ok && batchResponse {
+		opts = append(opts, WithBatchResponse())
+	}
+
+	return opts, env.err
+}
+
+// envReader parses environment variables, keeping the first error it comes across.
+type envReader struct {
+	lookup func(string) (string, bool)
+	err    error
+}
+
+func (e *envReader) fail(name, value string, err error) {
+	if e.err == nil {
+		e.err = fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+	}
+}
+
+func (e *envReader) string(name string) (string, bool) {
+	value, ok := e.lookup(name)
+	value = strings.TrimSpace(value)
+	return value, ok && value != ""
+}
+
+// endpoint returns the first of the endpoint variables that's set.
+func (e *envReader) endpoint() (string, bool) {
+	for _, name := range endpointVariables {
+		if endpoint, ok := e.string(name); ok {
+			return endpoint, true
+		}
+	}
+	return "", false
+}
+
+func (e *envReader) int(name string) (int, bool) {
+	value, ok := e.string(name)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		e.fail(name, value, err)
+		return 0, false
+	}
+	return n, true
+}
+
+func (e *envReader) float(name string) (float64, bool) {
+	value, ok := e.string(name)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		e.fail(name, value, err)
+		return 0, false
+	}
+	return f, true
+}
+
+func (e *envReader) bool(name string) (bool, bool) {
+	value, ok := e.string(name)
+	if !ok {
+		return false, false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.fail(name, value, err)
+		return false, false
+	}
+	return b, true
+}
+
+func (e *envReader) duration(name string) (time.Duration, bool) {
+	value, ok := e.string(name)
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		e.fail(name, value, err)
+		return 0, false
+	}
+	return d, true
+}
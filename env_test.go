@@ -0,0 +1,161 @@
+package sqsworker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	env := map[string]string{
+		EnvMaxConcurrency:  "4",
+		EnvDeadLetterURL:   "https://sqs.us-west-2.amazonaws.com/123456/dlq",
+		EnvMaxReceiveCount: "5",
+		EnvDeletePolicy:    "always",
+		EnvBatchDelete:     "true",
+		EnvEndpoint:        "http://localhost:4566",
+		EnvFIFO:            "true",
+		EnvMaxRetries:      "3",
+		EnvRetryDelay:      "250ms",
+		EnvDeadlineMargin:  "2s",
+		EnvRateLimit:       "10",
+		EnvRateBurst:       "5",
+		EnvDryRun:          "1",
+		EnvBatchResponse:   "true",
+		EnvMessageTimeout:  "30s",
+		EnvChunkSize:       "100",
+	}
+
+	opts, err := optionsFromEnv(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := NewHandler(&fakeSQSClient{}, nil, opts...)
+
+	if handler.concurrency != 4 || handler.maxReceive != 5 || !handler.batchDelete || !handler.fifo || handler.deletePolicy != DeleteAlways {
+		t.Errorf("unexpected handler %+v", handler)
+	}
+	if handler.deadLetter != env[EnvDeadLetterURL] {
+		t.Errorf("expected dead-letter queue %s, got %s", env[EnvDeadLetterURL], handler.deadLetter)
+	}
+	if handler.resolver != (ARNQueueURLResolver{Endpoint: "http://localhost:4566"}) {
+		t.Errorf("expected the endpoint to be used for queue URLs, got %+v", handler.resolver)
+	}
+	if handler.retry != (ExponentialBackoff{MaxRetries: 3, BaseDelay: 250 * time.Millisecond}) {
+		t.Errorf("unexpected retry policy %+v", handler.retry)
+	}
+	if handler.margin != 2*time.Second {
+		t.Errorf("expected a 2s deadline margin, got %v", handler.margin)
+	}
+	if handler.chunkSize != 100 {
+		t.Errorf("expected chunks of 100, got %d", handler.chunkSize)
+	}
+	if handler.messageTimeout != 30*time.Second {
+		t.Errorf("expected a 30s message timeout, got %v", handler.messageTimeout)
+	}
+	if !handler.dryRun.enabled {
+		t.Error("expected a dry run")
+	}
+	if !handler.batchResponse {
+		t.Error("expected batch responses")
+	}
+	if handler.limiter == nil || handler.limiter.Limit() != 10 || handler.limiter.Burst() != 5 {
+		t.Errorf("unexpected rate limiter %+v", handler.limiter)
+	}
+}
+
+func TestOptionsFromEnvInvalid(t *testing.T) {
+	cases := map[string]string{
+		EnvMaxConcurrency: "lots",
+		EnvDeletePolicy:   "sometimes",
+		EnvFIFO:           "maybe",
+		EnvDeadlineMargin: "2",
+	}
+
+	for name, value := range cases {
+		_, err := optionsFromEnv(func(key string) (string, bool) {
+			return value, key == name
+		})
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected an error naming %s, got %v", name, err)
+		}
+	}
+}
+
+func TestOptionsFromEnvBatchDelete(t *testing.T) {
+	// batch deletes have their own variable, so aren't a delete policy
+	for _, policy := range []string{"batch", "individual"} {
+		_, err := optionsFromEnv(func(name string) (string, bool) {
+			return policy, name == EnvDeletePolicy
+		})
+		if err == nil {
+			t.Errorf("expected %q to be rejected as a delete policy", policy)
+		}
+	}
+
+	opts, err := optionsFromEnv(func(name string) (string, bool) {
+		return "true", name == EnvBatchDelete
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := NewHandler(&fakeSQSClient{}, nil, opts...)
+	if !handler.batchDelete || handler.deletePolicy != DeleteOnSuccess {
+		t.Errorf("expected batch deletes with the default delete policy, got %v and %v", handler.batchDelete, handler.deletePolicy)
+	}
+}
+
+func TestNewHandlerFromEnv(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv(EnvEndpoint, "http://localhost:4566")
+	t.Setenv(EnvMaxConcurrency, "2")
+
+	handler, err := NewHandlerFromEnv(func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithMaxConcurrency(3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	client, ok := handler.sqsClient.(*sqs.SQS)
+	if !ok || client.Endpoint != "http://localhost:4566" {
+		t.Errorf("expected an SQS client using the endpoint, got %+v", handler.sqsClient)
+	}
+	if handler.concurrency != 3 {
+		t.Errorf("expected explicit options to override the environment, got %d", handler.concurrency)
+	}
+
+	t.Setenv(EnvMaxReceiveCount, "none")
+	if _, err := NewHandlerFromEnv(nil); err == nil {
+		t.Error("expected an error for an invalid variable")
+	}
+}
+
+func TestOptionsFromEnvEndpointFallback(t *testing.T) {
+	env := map[string]string{
+		"AWS_ENDPOINT_URL":     "http://global:4566",
+		"AWS_ENDPOINT_URL_SQS": "http://sqs:4566",
+	}
+
+	opts, err := optionsFromEnv(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := NewHandler(&fakeSQSClient{}, nil, opts...)
+	if handler.resolver != (ARNQueueURLResolver{Endpoint: "http://sqs:4566"}) {
+		t.Errorf("expected the SQS specific endpoint to be used, got %+v", handler.resolver)
+	}
+}
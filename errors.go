@@ -0,0 +1,142 @@
+package sqsworker
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrorClass describes how the handler should treat a message that failed processing.
+type ErrorClass int
+
+const (
+	// ClassTransient errors are retried according to the handler's RetryPolicy and
+	// the message is left on the queue for redelivery if it keeps failing.
+	ClassTransient ErrorClass = iota
+	// ClassRedeliver errors are not retried within the invocation; the message is
+	// left on the queue for redelivery straight away.
+	ClassRedeliver
+	// ClassPermanent errors will never succeed, so the message is removed from the
+	// queue immediately instead of going through the queue's redrive policy.
+	ClassPermanent
+)
+
+// String returns a human readable name for the class.
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassTransient:
+		return "transient"
+	case ClassRedeliver:
+		return "redeliver"
+	case ClassPermanent:
+		return "permanent"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrorClassifier decides the ErrorClass of errors returned by a processor.
+type ErrorClassifier interface {
+	Classify(err error) ErrorClass
+}
+
+// ErrorClassifierFunc allows an ordinary function to be used as an ErrorClassifier.
+type ErrorClassifierFunc func(err error) ErrorClass
+
+// Classify implements the ErrorClassifier interface.
+func (f ErrorClassifierFunc) Classify(err error) ErrorClass {
+	return f(err)
+}
+
+// classifiedError is an error that has been explicitly given a class by the processor.
+type classifiedError struct {
+	class ErrorClass
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error as permanent so that the message is removed from the
+// queue rather than retried.  A nil error returns nil.
+func Permanent(err error) error {
+	return classify(ClassPermanent, err)
+}
+
+// Transient marks an error as transient so that the message is retried.  A nil
+// error returns nil.
+func Transient(err error) error {
+	return classify(ClassTransient, err)
+}
+
+// RetryIn marks an error as one that should be retried after the given delay, such
+// as when a downstream service is rate limiting.  The message isn't retried within
+// the invocation; instead its visibility timeout is changed so that SQS redelivers it
+// once the delay has passed.  A nil error is reported as ErrRetry.
+func RetryIn(delay time.Duration, err error) error {
+	return &delayedError{
+		delay: delay,
+		err:   classify(ClassRedeliver, orDefault(err, ErrRetry)),
+	}
+}
+
+func classify(class ErrorClass, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// permanentByDefault marks an error as permanent unless it has already been given a
+// class, for failures such as undecodable payloads that only succeed if the cause is
+// known to be temporary.
+func permanentByDefault(err error) error {
+	var ce *classifiedError
+	if err == nil || errors.As(err, &ce) {
+		return err
+	}
+	return Permanent(err)
+}
+
+// classifyError works out the class of an error, preferring any class given
+// explicitly with Permanent or Transient over the classifier.  Errors are
+// transient unless told otherwise.
+func classifyError(classifier ErrorClassifier, err error) ErrorClass {
+	var ce *classifiedError
+	if errors.As(err, &ce) {
+		return ce.class
+	}
+
+	if classifier != nil {
+		return classifier.Classify(err)
+	}
+
+	return ClassTransient
+}
+
+// delayedError is an error for a message that should be redelivered after a delay.
+type delayedError struct {
+	delay time.Duration
+	err   error
+}
+
+func (e *delayedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *delayedError) Unwrap() error {
+	return e.err
+}
+
+// retryDelay returns the redelivery delay requested by an error, if any.
+func retryDelay(err error) (time.Duration, bool) {
+	var de *delayedError
+	if errors.As(err, &de) {
+		return de.delay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,122 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestClassifyError(t *testing.T) {
+	base := errors.New("boom")
+	redeliver := ErrorClassifierFunc(func(err error) ErrorClass { return ClassRedeliver })
+
+	cases := []struct {
+		name       string
+		err        error
+		classifier ErrorClassifier
+		expected   ErrorClass
+	}{
+		{"unclassified", base, nil, ClassTransient},
+		{"permanent", Permanent(base), nil, ClassPermanent},
+		{"transient", Transient(base), redeliver, ClassTransient},
+		{"wrapped permanent", fmt.Errorf("context: %w", Permanent(base)), nil, ClassPermanent},
+		{"classifier", base, redeliver, ClassRedeliver},
+	}
+
+	for _, c := range cases {
+		if class := classifyError(c.classifier, c.err); class != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, class)
+		}
+	}
+}
+
+func TestPermanentNil(t *testing.T) {
+	if Permanent(nil) != nil || Transient(nil) != nil {
+		t.Error("expected nil errors to stay nil")
+	}
+}
+
+func TestPermanentUnwrap(t *testing.T) {
+	base := errors.New("boom")
+	if !errors.Is(Permanent(base), base) {
+		t.Error("expected the original error to be unwrappable")
+	}
+}
+
+func TestPermanentErrorIsDeleted(t *testing.T) {
+	client := &fakeSQSClient{}
+	calls := 0
+	processor := func(ctx context.Context, msg events.SQSMessage) error {
+		calls++
+		return Permanent(errors.New("bad payload"))
+	}
+
+	handler := NewHandler(client, processor, WithRetry(ExponentialBackoff{MaxRetries: 3, BaseDelay: time.Millisecond}))
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if err != nil || completed != 1 {
+		t.Errorf("expected the message to be closed, got %d completed and error %v", completed, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected permanent errors not to be retried, got %d calls", calls)
+	}
+	if len(client.deleted) != 1 {
+		t.Error("expected the message to be deleted")
+	}
+}
+
+func TestRedeliverErrorIsNotRetried(t *testing.T) {
+	client := &fakeSQSClient{}
+	calls := 0
+	processor := func(ctx context.Context, msg events.SQSMessage) error {
+		calls++
+		return errors.New("rate limited")
+	}
+
+	handler := NewHandler(client, processor,
+		WithRetry(ExponentialBackoff{MaxRetries: 3, BaseDelay: time.Millisecond}),
+		WithErrorClassifier(ErrorClassifierFunc(func(err error) ErrorClass { return ClassRedeliver })),
+	)
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if completed != 0 || calls != 1 || len(client.deleted) != 0 {
+		t.Errorf("expected a single attempt that leaves the message, got %d calls and %d deletes", calls, len(client.deleted))
+	}
+}
+
+func TestRetryIn(t *testing.T) {
+	base := errors.New("rate limited")
+	err := fmt.Errorf("calling api: %w", RetryIn(5*time.Minute, base))
+
+	if delay, ok := retryDelay(err); !ok || delay != 5*time.Minute {
+		t.Errorf("expected a 5 minute delay, got %v", delay)
+	}
+	if !errors.Is(err, base) || classifyError(nil, err) != ClassRedeliver {
+		t.Errorf("expected the error to be redelivered and unwrap to the cause")
+	}
+	if !errors.Is(RetryIn(time.Second, nil), ErrRetry) {
+		t.Error("expected a nil error to be reported as ErrRetry")
+	}
+}
+
+func TestRetryInChangesVisibility(t *testing.T) {
+	client := &fakeSQSClient{}
+	calls := 0
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		calls++
+		return RetryIn(2*time.Minute, errors.New("rate limited"))
+	}, WithRetry(ExponentialBackoff{MaxRetries: 3}))
+
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if completed != 0 || calls != 1 {
+		t.Errorf("expected a single failed attempt, got %d calls", calls)
+	}
+	if client.visibility["handle-1"] != 120 {
+		t.Errorf("expected the visibility timeout to be set to 120 seconds, got %v", client.visibility)
+	}
+}
@@ -0,0 +1,52 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// UnwrapEventBridge is a Middleware for queues targeted by EventBridge rules.  When a
+// message's body is an EventBridge event, the processor receives the event's detail as
+// the body, while the rest of the event, such as its detail type and source, is
+// available through EventBridgeFromContext.  Other messages are passed through
+// untouched.
+func UnwrapEventBridge(next MessageProcessor) MessageProcessor {
+	return func(ctx context.Context, msg events.SQSMessage) error {
+		var event events.CloudWatchEvent
+		if err := json.Unmarshal([]byte(msg.Body), &event); err != nil ||
+			event.DetailType == "" || event.Source == "" || len(event.Detail) == 0 {
+			return next(ctx, msg)
+		}
+
+		msg.Body = string(event.Detail)
+
+		return next(context.WithValue(ctx, eventBridgeKey, event), msg)
+	}
+}
+
+// EventBridgeFromContext returns the EventBridge event of the message being processed,
+// if it was unwrapped by UnwrapEventBridge.
+func EventBridgeFromContext(ctx context.Context) (events.CloudWatchEvent, bool) {
+	event, ok := ctx.Value(eventBridgeKey).(events.CloudWatchEvent)
+	return event, ok
+}
+
+// MatchDetailType creates a Matcher for messages unwrapped by UnwrapEventBridge with
+// the given detail type.
+func MatchDetailType(detailType string) Matcher {
+	return func(ctx context.Context, msg events.SQSMessage) bool {
+		event, ok := EventBridgeFromContext(ctx)
+		return ok && event.DetailType == detailType
+	}
+}
+
+// MatchSource creates a Matcher for messages unwrapped by UnwrapEventBridge from the
+// given source.
+func MatchSource(source string) Matcher {
+	return func(ctx context.Context, msg events.SQSMessage) bool {
+		event, ok := EventBridgeFromContext(ctx)
+		return ok && event.Source == source
+	}
+}
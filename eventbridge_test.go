@@ -0,0 +1,64 @@
+package sqsworker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const testEventBridgeBody = `{
+  "version": "0",
+  "id": "abc",
+  "detail-type": "Order Placed",
+  "source": "com.example.orders",
+  "account": "123456",
+  "time": "2020-01-01T00:00:00Z",
+  "region": "us-west-2",
+  "resources": [],
+  "detail": {"id": 1}
+}`
+
+func TestUnwrapEventBridge(t *testing.T) {
+	var got events.SQSMessage
+	var event events.CloudWatchEvent
+	var ok bool
+
+	processor := UnwrapEventBridge(func(ctx context.Context, msg events.SQSMessage) error {
+		got = msg
+		event, ok = EventBridgeFromContext(ctx)
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = testEventBridgeBody
+	processor(context.Background(), msg)
+
+	if got.Body != `{"id": 1}` {
+		t.Errorf("expected the detail as the body, got %s", got.Body)
+	}
+	if !ok || event.DetailType != "Order Placed" || event.Source != "com.example.orders" {
+		t.Errorf("expected the event to be in the context, got %+v", event)
+	}
+}
+
+func TestUnwrapEventBridgeRouting(t *testing.T) {
+	var placed, other []string
+	router := NewRouter("")
+	router.HandleMatch(MatchDetailType("Order Placed"), recordingProcessor(&placed))
+	router.HandleMatch(MatchSource("com.example.other"), recordingProcessor(&other))
+
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, router.Process, WithMiddleware(UnwrapEventBridge))
+
+	msg := testMessage("1")
+	msg.Body = testEventBridgeBody
+	plain := testMessage("2")
+	plain.Body = `{"detail-type":"Order Placed"}`
+
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{msg, plain})
+
+	if completed != 1 || len(placed) != 1 || len(other) != 0 {
+		t.Errorf("expected only the EventBridge event to be routed, got %d completed, %v and %v", completed, placed, other)
+	}
+}
@@ -0,0 +1,107 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3PointerClasses are the class names the SQS Extended Client libraries use to mark a
+// message body as a pointer to an S3 object.
+var s3PointerClasses = map[string]bool{
+	"software.amazon.payloadoffloading.PayloadS3Pointer": true,
+	"com.amazon.sqs.javamessaging.MessageS3Pointer":      true,
+}
+
+// PartialS3Client is a partial S3 client that can fetch and delete objects.
+type PartialS3Client interface {
+	GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error)
+	DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error)
+}
+
+// s3Pointer is the location of a payload offloaded to S3.
+type s3Pointer struct {
+	Bucket string `json:"s3BucketName"`
+	Key    string `json:"s3Key"`
+}
+
+// ExtendedPayload creates a Middleware for messages sent with the SQS Extended Client,
+// which offloads large payloads to S3 and sends a pointer to the object instead.  The
+// processor receives the payload fetched from S3 as the body.  When deleteObject is set,
+// the object is deleted once the message has been processed successfully.  Messages
+// that don't hold a pointer are passed through untouched, and pointers to objects that
+// don't exist fail permanently.
+func ExtendedPayload(client PartialS3Client, deleteObject bool) Middleware {
+	return func(next MessageProcessor) MessageProcessor {
+		return func(ctx context.Context, msg events.SQSMessage) error {
+			pointer, ok := parseS3Pointer(msg.Body)
+			if !ok {
+				return next(ctx, msg)
+			}
+
+			out, err := client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+				Bucket: aws.String(pointer.Bucket),
+				Key:    aws.String(pointer.Key),
+			})
+			if err != nil {
+				aerr, ok := err.(awserr.Error)
+				err = fmt.Errorf("failed to fetch payload s3://%s/%s: %w", pointer.Bucket, pointer.Key, err)
+				if ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+					return Permanent(err)
+				}
+				return err
+			}
+			defer out.Body.Close()
+
+			payload, err := io.ReadAll(out.Body)
+			if err != nil {
+				return fmt.Errorf("failed to read payload s3://%s/%s: %w", pointer.Bucket, pointer.Key, err)
+			}
+
+			msg.Body = string(payload)
+			if err := next(ctx, msg); err != nil {
+				return err
+			}
+
+			if deleteObject {
+				_, err := client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+					Bucket: aws.String(pointer.Bucket),
+					Key:    aws.String(pointer.Key),
+				})
+				if err != nil {
+					Logf(ctx, "failed to delete payload s3://%s/%s: %v", pointer.Bucket, pointer.Key, err)
+				}
+			}
+
+			return nil
+		}
+	}
+}
+
+// parseS3Pointer reads the S3 pointer from a message body of the form
+// ["<class name>", {"s3BucketName": "...", "s3Key": "..."}].
+func parseS3Pointer(body string) (s3Pointer, bool) {
+	var parts []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &parts); err != nil || len(parts) != 2 {
+		return s3Pointer{}, false
+	}
+
+	var class string
+	if err := json.Unmarshal(parts[0], &class); err != nil || !s3PointerClasses[class] {
+		return s3Pointer{}, false
+	}
+
+	var pointer s3Pointer
+	if err := json.Unmarshal(parts[1], &pointer); err != nil || pointer.Bucket == "" || pointer.Key == "" {
+		return s3Pointer{}, false
+	}
+
+	return pointer, true
+}
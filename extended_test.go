@@ -0,0 +1,115 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// fakeS3Client is a PartialS3Client backed by a map of "bucket/key" to contents.
+type fakeS3Client struct {
+	objects   map[string]string
+	deleted   []string
+	deleteErr error
+}
+
+func (f *fakeS3Client) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	body, ok := f.objects[*input.Bucket+"/"+*input.Key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (f *fakeS3Client) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	if f.deleteErr != nil {
+		return nil, f.deleteErr
+	}
+	f.deleted = append(f.deleted, *input.Bucket+"/"+*input.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+const testS3PointerBody = `["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"payloads","s3Key":"abc"}]`
+
+func TestExtendedPayload(t *testing.T) {
+	client := &fakeS3Client{objects: map[string]string{"payloads/abc": "large payload"}}
+	var body string
+	failure := errors.New("failed")
+	var result error
+
+	processor := ExtendedPayload(client, true)(func(ctx context.Context, msg events.SQSMessage) error {
+		body = msg.Body
+		return result
+	})
+
+	msg := testMessage("1")
+	msg.Body = testS3PointerBody
+
+	result = failure
+	if err := processor(context.Background(), msg); err != failure || len(client.deleted) != 0 {
+		t.Errorf("expected a failed message to keep its payload, got %v and %v", err, client.deleted)
+	}
+
+	result = nil
+	if err := processor(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if body != "large payload" {
+		t.Errorf("expected the payload from S3 as the body, got %s", body)
+	}
+	if len(client.deleted) != 1 || client.deleted[0] != "payloads/abc" {
+		t.Errorf("expected the payload to be deleted after success, got %v", client.deleted)
+	}
+}
+
+func TestExtendedPayloadMissingObject(t *testing.T) {
+	processor := ExtendedPayload(&fakeS3Client{}, false)(func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = testS3PointerBody
+	if err := processor(context.Background(), msg); classifyError(nil, err) != ClassPermanent {
+		t.Errorf("expected a missing payload to fail permanently, got %v", err)
+	}
+}
+
+func TestExtendedPayloadDeleteFailure(t *testing.T) {
+	var buf bytes.Buffer
+	client := &fakeS3Client{objects: map[string]string{"payloads/abc": "large payload"}, deleteErr: errors.New("access denied")}
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithMiddleware(ExtendedPayload(client, true)), WithLogger(log.New(&buf, "", 0)))
+
+	msg := testMessage("1")
+	msg.Body = testS3PointerBody
+	if completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{msg}); completed != 1 {
+		t.Errorf("expected the message to complete anyway, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "failed to delete payload s3://payloads/abc: access denied") {
+		t.Errorf("expected the failure to go to the handler's logger, got %q", buf.String())
+	}
+}
+
+func TestParseS3Pointer(t *testing.T) {
+	legacy := `["com.amazon.sqs.javamessaging.MessageS3Pointer",{"s3BucketName":"b","s3Key":"k"}]`
+	if pointer, ok := parseS3Pointer(legacy); !ok || pointer.Bucket != "b" || pointer.Key != "k" {
+		t.Errorf("expected the legacy pointer format to be parsed, got %v", pointer)
+	}
+
+	for _, body := range []string{`["a","b"]`, `{"s3BucketName":"b"}`, `["software.amazon.payloadoffloading.PayloadS3Pointer",{}]`, `plain`} {
+		if _, ok := parseS3Pointer(body); ok {
+			t.Errorf("expected %s not to be a pointer", body)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrCancelled is reported for messages that were cancelled, or never started, because
+// another message in the batch failed with WithFailFast.  Cancelled messages are left
+// on the queue.
+var ErrCancelled = errors.New("message cancelled after another message in the batch failed")
+
+// notStartedError is the error for a message that wasn't started because the batch's
+// context was already done.
+func notStartedError(ctx context.Context) error {
+	cause := context.Cause(ctx)
+	switch {
+	case errors.Is(cause, ErrCancelled):
+		return ErrCancelled
+	case errors.Is(cause, context.DeadlineExceeded):
+		return ErrDeadline
+	}
+	return cause
+}
+
+// cancelledError reports whether a message's failure was down to the batch failing
+// fast, rather than the message itself, and if so returns the error to report for it.
+func cancelledError(ctx context.Context, err error) (error, bool) {
+	if err == nil || !errors.Is(context.Cause(ctx), ErrCancelled) {
+		return err, false
+	}
+	return fmt.Errorf("%w: %v", ErrCancelled, err), true
+}
+
+// withFailFast gives the context a way for failBatch to cancel the rest of the batch.
+func withFailFast(ctx context.Context) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return context.WithValue(ctx, failFastKey, cancel), cancel
+}
+
+// failBatch cancels the rest of a fail fast batch if the message failed.  It's called as
+// soon as the message is closed, so that no more messages are started in the meantime.
+func failBatch(ctx context.Context, res outcome) {
+	if cancel, ok := ctx.Value(failFastKey).(context.CancelCauseFunc); ok && failsBatch(res) {
+		cancel(ErrCancelled)
+	}
+}
+
+// failsBatch reports whether an outcome should stop the rest of a fail fast batch.
+// Messages that were only skipped or cancelled don't count.
+func failsBatch(res outcome) bool {
+	return res.err != nil && !errors.Is(res.err, ErrCancelled) &&
+		!errors.Is(res.err, ErrSkipped) && !errors.Is(res.err, ErrDeadline)
+}
@@ -0,0 +1,79 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithFailFast(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "bad" {
+			return errors.New("failed")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithFailFast(), WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		testMessage("slow"), testMessage("bad"), testMessage("slower"),
+	})
+
+	var batchErr *BatchError
+	if completed != 0 || !errors.As(err, &batchErr) || len(batchErr.Errors) != 3 {
+		t.Fatalf("expected all messages to fail, got %d: %v", completed, err)
+	}
+	for _, msgErr := range batchErr.Errors {
+		cancelled := errors.Is(msgErr.Err, ErrCancelled)
+		if cancelled != (msgErr.MessageID != "bad") {
+			t.Errorf("expected only messages other than bad to be cancelled, got %s: %v", msgErr.MessageID, msgErr.Err)
+		}
+	}
+	if len(client.deleted) != 0 || len(client.visibility) != 0 {
+		t.Errorf("expected all messages to be left as they are, got deletes %v and visibility %v", client.deleted, client.visibility)
+	}
+}
+
+func TestFailFastNotStarted(t *testing.T) {
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		t.Error("expected the processor not to be called")
+		return nil
+	}, WithLogger(nil))
+
+	ctx, cancel := withFailFast(context.Background())
+	failBatch(ctx, outcome{err: errors.New("failed")})
+	defer cancel(nil)
+
+	if res := handler.handleMessage(ctx, testMessage("1")); res.err != ErrCancelled {
+		t.Errorf("expected the message to be cancelled, got %v", res.err)
+	}
+}
+
+func TestFailFastIgnoresSkipped(t *testing.T) {
+	ctx, cancel := withFailFast(context.Background())
+	defer cancel(nil)
+
+	failBatch(ctx, outcome{err: ErrSkipped})
+	failBatch(ctx, outcome{err: ErrDeadline})
+	if ctx.Err() != nil {
+		t.Error("expected skipped messages not to cancel the batch")
+	}
+}
+
+func TestWithoutFailFast(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "bad" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithMaxConcurrency(1), WithLogger(nil))
+
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("bad"), testMessage("good")})
+	if completed != 1 || len(client.deleted) != 1 {
+		t.Errorf("expected the good message to complete, got %d: %v", completed, client.deleted)
+	}
+}
@@ -0,0 +1,67 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrSkipped is reported for FIFO messages that weren't processed because an earlier
+// message in the same group failed, or for any later message in the batch with
+// WithSequential.
+var ErrSkipped = errors.New("message skipped after an earlier message in its group failed")
+
+// groupMessages splits messages by their MessageGroupId, sorting each group by sequence
+// number.  Groups are returned in the order they first appear in the batch.
+func groupMessages(messages []events.SQSMessage) [][]events.SQSMessage {
+	var groups [][]events.SQSMessage
+	index := map[string]int{}
+
+	for _, msg := range messages {
+		id := msg.Attributes["MessageGroupId"]
+		i, ok := index[id]
+		if !ok {
+			i = len(groups)
+			index[id] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], msg)
+	}
+
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return lessSequenceNumber(group[i].Attributes["SequenceNumber"], group[j].Attributes["SequenceNumber"])
+		})
+	}
+
+	return groups
+}
+
+// lessSequenceNumber compares two sequence numbers, which are too large to fit in an
+// integer but can be compared as strings of digits once their lengths are taken into
+// account.
+func lessSequenceNumber(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// handleGroup processes the messages of a single group in order, skipping the rest of
+// the group once a message fails.
+func (s *Handler) handleGroup(ctx context.Context, ch chan<- outcome, group []events.SQSMessage) {
+	failed := false
+
+	for _, msg := range group {
+		if failed {
+			ch <- outcome{msg: msg, err: ErrSkipped}
+			continue
+		}
+
+		res := s.handleMessage(ctx, msg)
+		failed = res.err != nil
+		ch <- res
+	}
+}
@@ -0,0 +1,109 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// fifoMessage creates a message from a FIFO queue.
+func fifoMessage(id, group, sequence string) events.SQSMessage {
+	msg := testMessage(id)
+	msg.Attributes = map[string]string{"MessageGroupId": group, "SequenceNumber": sequence}
+	return msg
+}
+
+func TestGroupMessages(t *testing.T) {
+	groups := groupMessages([]events.SQSMessage{
+		fifoMessage("a2", "a", "100000000000000000020"),
+		fifoMessage("b1", "b", "5"),
+		fifoMessage("a1", "a", "99999999999999999999"),
+	})
+
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	if groups[0][0].MessageId != "a1" || groups[0][1].MessageId != "a2" || groups[1][0].MessageId != "b1" {
+		t.Errorf("expected groups to be sorted by sequence number, got %v", groups)
+	}
+}
+
+func TestWithFIFO(t *testing.T) {
+	client := &fakeSQSClient{}
+	var mu sync.Mutex
+	var order []string
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		mu.Lock()
+		order = append(order, msg.MessageId)
+		mu.Unlock()
+		if msg.MessageId == "a2" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithFIFO())
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		fifoMessage("a3", "a", "3"),
+		fifoMessage("a1", "a", "1"),
+		fifoMessage("a2", "a", "2"),
+		fifoMessage("b1", "b", "4"),
+	})
+
+	if err == nil || completed != 2 {
+		t.Errorf("expected 2 completed messages and an error, got %d and %v", completed, err)
+	}
+
+	var groupA []string
+	for _, id := range order {
+		if id[0] == 'a' {
+			groupA = append(groupA, id)
+		}
+	}
+	if !reflect.DeepEqual(groupA, []string{"a1", "a2"}) {
+		t.Errorf("expected group a to stop after its failure, got %v", groupA)
+	}
+	if len(client.deleted) != 2 {
+		t.Errorf("expected only a1 and b1 to be deleted, got %v", client.deleted)
+	}
+}
+
+func TestWithSequential(t *testing.T) {
+	client := &fakeSQSClient{}
+	var order []string
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		order = append(order, msg.MessageId)
+		if msg.MessageId == "3" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithSequential(), WithLogger(nil))
+
+	var messages []events.SQSMessage
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		messages = append(messages, testMessage(id))
+	}
+
+	completed, err := handler.ProcessMessages(context.Background(), messages)
+
+	if !reflect.DeepEqual(order, []string{"1", "2", "3"}) {
+		t.Errorf("expected messages to be processed in order up to the failure, got %v", order)
+	}
+	if completed != 2 || !reflect.DeepEqual(client.deleted, []string{"handle-1", "handle-2"}) {
+		t.Errorf("expected only the first two messages to be deleted, got %d: %v", completed, client.deleted)
+	}
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 3 {
+		t.Fatalf("expected three messages to fail, got %v", err)
+	}
+	for _, msgErr := range batchErr.Errors {
+		if skipped := errors.Is(msgErr.Err, ErrSkipped); skipped != (msgErr.MessageID != "3") {
+			t.Errorf("expected only the messages after the failure to be skipped, got %s: %v", msgErr.MessageID, msgErr.Err)
+		}
+	}
+}
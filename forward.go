@@ -0,0 +1,50 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// forwardMessage sends a message that completed on to the handler's forward queue, if
+// it has one.  The body is the output the processor gave with SetOutput, or the
+// original body if there isn't any, and the message attributes are carried over.
+func (s *Handler) forwardMessage(ctx context.Context, msg events.SQSMessage) error {
+	if s.forward == "" {
+		return nil
+	}
+
+	body := msg.Body
+	output, ok, err := outputJSON(ctx)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to encode output of message %s: %w", msg.MessageId, err))
+	}
+	if ok {
+		body = string(output)
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:          &s.forward,
+		MessageBody:       &body,
+		MessageAttributes: toMessageAttributes(withoutAttribute(msg.MessageAttributes, FailureAttribute)),
+	}
+	s.correlateSQS(ctx, input.MessageAttributes)
+
+	// keep the message's place in its group, and its deduplication ID, when moving
+	// between FIFO queues
+	if strings.HasSuffix(s.forward, ".fifo") {
+		input.MessageGroupId, input.MessageDeduplicationId = fifoIDs(msg)
+		if dedup := msg.Attributes["MessageDeduplicationId"]; dedup != "" {
+			input.MessageDeduplicationId = &dedup
+		}
+	}
+
+	if err := s.sendMessage(input); err != nil {
+		return fmt.Errorf("failed to forward message %s: %w", msg.MessageId, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestWithForwardQueue(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "transformed" {
+			SetOutput(ctx, map[string]int{"id": 1})
+		}
+		return nil
+	}, WithForwardQueue("https://sqs.us-west-2.amazonaws.com/123456/next"))
+
+	original := testMessage("original")
+	original.Body = "hello"
+	original.MessageAttributes = map[string]events.SQSMessageAttribute{
+		"type": {DataType: "String", StringValue: aws.String("greeting")},
+	}
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{original, testMessage("transformed")})
+
+	bodies := map[string]string{}
+	for _, input := range client.sent {
+		if *input.QueueUrl != "https://sqs.us-west-2.amazonaws.com/123456/next" {
+			t.Errorf("unexpected queue %s", *input.QueueUrl)
+		}
+		bodies[*input.MessageBody] = *input.MessageBody
+		if *input.MessageBody == "hello" && *input.MessageAttributes["type"].StringValue != "greeting" {
+			t.Errorf("expected the attributes to be kept, got %v", input.MessageAttributes)
+		}
+	}
+	if len(bodies) != 2 || bodies["hello"] == "" || bodies[`{"id":1}`] == "" {
+		t.Errorf("unexpected forwarded bodies %v", bodies)
+	}
+	if len(client.deleted) != 2 {
+		t.Errorf("expected both messages to be deleted, got %v", client.deleted)
+	}
+}
+
+func TestWithForwardQueueFIFO(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithForwardQueue("https://sqs.us-west-2.amazonaws.com/123456/next.fifo"))
+
+	msg := fifoMessage("1", "group", "1")
+	msg.Attributes["MessageDeduplicationId"] = "dedup"
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{msg})
+
+	if len(client.sent) != 1 || *client.sent[0].MessageGroupId != "group" || *client.sent[0].MessageDeduplicationId != "dedup" {
+		t.Errorf("expected the group and deduplication ID to be kept, got %+v", client.sent)
+	}
+}
+
+func TestWithForwardQueueFailure(t *testing.T) {
+	client := &fakeSQSClient{sendErr: errors.New("unavailable")}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithForwardQueue("https://sqs.us-west-2.amazonaws.com/123456/next"))
+
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+	if completed != 0 || len(client.deleted) != 0 {
+		t.Errorf("expected the message to be left on the queue, got %v", client.deleted)
+	}
+}
+
+func TestWithForwardQueueUnencodableOutput(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		SetOutput(ctx, make(chan int))
+		return nil
+	}, WithForwardQueue("https://sqs.us-west-2.amazonaws.com/123456/next"), WithDeadLetterQueue(testDeadLetterURL), WithLogger(nil))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	// the message is dead-lettered rather than forwarded, as its output will never encode
+	if len(client.sent) != 1 || *client.sent[0].QueueUrl != testDeadLetterURL || len(client.deleted) != 1 {
+		t.Errorf("expected the message to be dead-lettered, got %d sent and %v deleted", len(client.sent), client.deleted)
+	}
+}
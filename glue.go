@@ -0,0 +1,126 @@
+package sqsworker
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/hamba/avro/v2"
+)
+
+const (
+	// glueHeaderVersion is the first byte of every Glue Schema Registry payload.
+	glueHeaderVersion = 3
+	// glueHeaderSize is the length of the header: the version, the compression byte
+	// and the 16 byte schema version ID.
+	glueHeaderSize = 18
+
+	glueCompressionNone = 0
+	glueCompressionZlib = 5
+)
+
+// PartialGlueClient is a partial Glue client that can fetch schema versions.
+type PartialGlueClient interface {
+	GetSchemaVersionWithContext(ctx aws.Context, input *glue.GetSchemaVersionInput, opts ...request.Option) (*glue.GetSchemaVersionOutput, error)
+}
+
+// GlueAvroCodec is a Codec for Avro payloads serialised with the AWS Glue Schema
+// Registry, which prefixes each payload with the ID of the schema version it was
+// written with.  The schema is fetched from the registry the first time each ID is
+// seen and cached from then on.  Bodies are expected to be base64 encoded, and are
+// decoded into structs tagged with `avro:"name"` or into map[string]interface{}.
+type GlueAvroCodec struct {
+	client  PartialGlueClient
+	mu      sync.RWMutex
+	schemas map[string]avro.Schema
+}
+
+// NewGlueAvroCodec creates a GlueAvroCodec that fetches schemas with the given client.
+func NewGlueAvroCodec(client PartialGlueClient) *GlueAvroCodec {
+	return &GlueAvroCodec{
+		client:  client,
+		schemas: map[string]avro.Schema{},
+	}
+}
+
+// Decode implements the Codec interface.
+func (c *GlueAvroCodec) Decode(ctx context.Context, body []byte, v interface{}) error {
+	data, err := decodeBase64(body)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < glueHeaderSize || data[0] != glueHeaderVersion {
+		return fmt.Errorf("payload is not in the Glue Schema Registry format")
+	}
+
+	id := formatUUID(data[2:glueHeaderSize])
+	payload := data[glueHeaderSize:]
+
+	switch data[1] {
+	case glueCompressionNone:
+	case glueCompressionZlib:
+		reader, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		if payload, err = io.ReadAll(reader); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported Glue Schema Registry compression %d", data[1])
+	}
+
+	schema, err := c.schema(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return avro.Unmarshal(schema, payload, v)
+}
+
+// schema returns the parsed schema for the given schema version ID.
+func (c *GlueAvroCodec) schema(ctx context.Context, id string) (avro.Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.schemas[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	out, err := c.client.GetSchemaVersionWithContext(ctx, &glue.GetSchemaVersionInput{
+		SchemaVersionId: aws.String(id),
+	})
+	if err != nil {
+		// registry failures say nothing about the message, so they're worth retrying
+		return nil, Transient(fmt.Errorf("failed to fetch schema version %s: %w", id, err))
+	}
+
+	if format := aws.StringValue(out.DataFormat); format != glue.DataFormatAvro {
+		return nil, fmt.Errorf("schema version %s has data format %s, not AVRO", id, format)
+	}
+
+	schema, err = avro.Parse(aws.StringValue(out.SchemaDefinition))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema version %s: %w", id, err)
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+
+	return schema, nil
+}
+
+// formatUUID formats 16 bytes as a UUID string.
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
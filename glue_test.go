@@ -0,0 +1,115 @@
+package sqsworker
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/hamba/avro/v2"
+)
+
+const (
+	testSchemaID   = "01020304-0506-0708-090a-0b0c0d0e0f10"
+	testAvroSchema = `{"type":"record","name":"Order","fields":[{"name":"id","type":"long"},{"name":"email","type":"string"}]}`
+)
+
+// fakeGlueClient is a PartialGlueClient serving a single Avro schema.
+type fakeGlueClient struct {
+	calls int
+	err   error
+}
+
+func (f *fakeGlueClient) GetSchemaVersionWithContext(ctx aws.Context, input *glue.GetSchemaVersionInput, opts ...request.Option) (*glue.GetSchemaVersionOutput, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if *input.SchemaVersionId != testSchemaID {
+		return nil, errors.New("not found")
+	}
+	return &glue.GetSchemaVersionOutput{
+		DataFormat:       aws.String(glue.DataFormatAvro),
+		SchemaDefinition: aws.String(testAvroSchema),
+	}, nil
+}
+
+type avroOrder struct {
+	ID    int64  `avro:"id"`
+	Email string `avro:"email"`
+}
+
+// glueBody encodes an order in the Glue Schema Registry format.
+func glueBody(t *testing.T, order avroOrder, compress bool) string {
+	data, err := avro.Marshal(avro.MustParse(testAvroSchema), order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := []byte{glueHeaderVersion, glueCompressionNone, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	if compress {
+		header[1] = glueCompressionZlib
+		var buf bytes.Buffer
+		writer := zlib.NewWriter(&buf)
+		writer.Write(data)
+		writer.Close()
+		data = buf.Bytes()
+	}
+
+	return base64.StdEncoding.EncodeToString(append(header, data...))
+}
+
+func TestGlueAvroCodec(t *testing.T) {
+	client := &fakeGlueClient{}
+	var orders []avroOrder
+	processor := Typed(NewGlueAvroCodec(client), func(ctx context.Context, order avroOrder, msg events.SQSMessage) error {
+		orders = append(orders, order)
+		return nil
+	})
+
+	for _, compress := range []bool{false, true} {
+		msg := testMessage("1")
+		msg.Body = glueBody(t, avroOrder{ID: 7, Email: "a@example.com"}, compress)
+		if err := processor(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(orders) != 2 || orders[1].ID != 7 || orders[1].Email != "a@example.com" {
+		t.Errorf("unexpected orders %+v", orders)
+	}
+	if client.calls != 1 {
+		t.Errorf("expected the schema to be cached, got %d calls", client.calls)
+	}
+}
+
+func TestGlueAvroCodecMap(t *testing.T) {
+	var order map[string]interface{}
+	err := NewGlueAvroCodec(&fakeGlueClient{}).Decode(context.Background(), []byte(glueBody(t, avroOrder{ID: 7}, false)), &order)
+	if err != nil || order["id"] != int64(7) {
+		t.Errorf("expected to decode into a map, got %v and %v", order, err)
+	}
+}
+
+func TestGlueAvroCodecErrors(t *testing.T) {
+	processor := Typed(NewGlueAvroCodec(&fakeGlueClient{err: errors.New("throttled")}), func(ctx context.Context, order avroOrder, msg events.SQSMessage) error {
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = glueBody(t, avroOrder{ID: 7}, false)
+	if err := processor(context.Background(), msg); classifyError(nil, err) != ClassTransient {
+		t.Errorf("expected registry failures to be transient, got %v", err)
+	}
+
+	msg.Body = base64.StdEncoding.EncodeToString([]byte("plain"))
+	if err := processor(context.Background(), msg); classifyError(nil, err) != ClassPermanent {
+		t.Errorf("expected invalid payloads to fail permanently, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package sqsworker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// heartbeat configures how often in-flight messages have their visibility extended.
+type heartbeat struct {
+	interval  time.Duration
+	extension time.Duration
+}
+
+// startHeartbeat periodically extends the visibility timeout of a message until the
+// returned function is called.  The function waits for the heartbeat to finish so
+// that no extension can override a visibility change made after processing.
+func (s *Handler) startHeartbeat(ctx context.Context, msg events.SQSMessage) (stop func()) {
+	if s.heartbeat.interval <= 0 || !fromSQS(msg) {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(s.heartbeat.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.changeVisibility(ctx, msg, s.heartbeat.extension)
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
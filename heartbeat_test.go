@@ -0,0 +1,43 @@
+package sqsworker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithHeartbeat(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewResultHandler(client, func(ctx context.Context, msg events.SQSMessage) Result {
+		time.Sleep(30 * time.Millisecond)
+		if msg.MessageId == "later" {
+			return RetryAfter(10*time.Second, nil)
+		}
+		return Ack()
+	}, WithHeartbeat(5*time.Millisecond, time.Minute))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1"), testMessage("later")})
+
+	if client.visibility["handle-1"] != 60 {
+		t.Errorf("expected the heartbeat to extend visibility to 60 seconds, got %v", client.visibility)
+	}
+	if client.visibility["handle-later"] != 10 {
+		t.Errorf("expected the retry delay to win over the heartbeat, got %v", client.visibility)
+	}
+}
+
+func TestWithoutHeartbeat(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if len(client.visibility) != 0 {
+		t.Errorf("expected no visibility changes, got %v", client.visibility)
+	}
+}
@@ -0,0 +1,59 @@
+package sqsworker
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Hooks are functions called as each message and batch is handled, for things like
+// logging and tracing that shouldn't live in the processor itself.  Any hook can be left
+// nil.
+type Hooks struct {
+	// BeforeMessage is called before the handler starts on a message.
+	BeforeMessage func(ctx context.Context, msg events.SQSMessage)
+	// AfterMessage is called once the handler has finished with a message, with the
+	// error it was left with, or nil if it was closed.
+	AfterMessage func(ctx context.Context, msg events.SQSMessage, err error)
+	// BeforeBatch is called before the handler starts on a batch.
+	BeforeBatch func(ctx context.Context, messages []events.SQSMessage)
+	// AfterBatch is called once the handler has finished with a batch, with the number
+	// of messages closed and the error ProcessMessages returns.
+	AfterBatch func(ctx context.Context, messages []events.SQSMessage, completed int, err error)
+}
+
+// beforeMessage calls the BeforeMessage hook of each set of hooks in order.
+func (s *Handler) beforeMessage(ctx context.Context, msg events.SQSMessage) {
+	for _, h := range s.hooks {
+		if h.BeforeMessage != nil {
+			h.BeforeMessage(ctx, msg)
+		}
+	}
+}
+
+// afterMessage calls the AfterMessage hook of each set of hooks in order.
+func (s *Handler) afterMessage(ctx context.Context, msg events.SQSMessage, err error) {
+	for _, h := range s.hooks {
+		if h.AfterMessage != nil {
+			h.AfterMessage(ctx, msg, err)
+		}
+	}
+}
+
+// beforeBatch calls the BeforeBatch hook of each set of hooks in order.
+func (s *Handler) beforeBatch(ctx context.Context, messages []events.SQSMessage) {
+	for _, h := range s.hooks {
+		if h.BeforeBatch != nil {
+			h.BeforeBatch(ctx, messages)
+		}
+	}
+}
+
+// afterBatch calls the AfterBatch hook of each set of hooks in order.
+func (s *Handler) afterBatch(ctx context.Context, messages []events.SQSMessage, completed int, err error) {
+	for _, h := range s.hooks {
+		if h.AfterBatch != nil {
+			h.AfterBatch(ctx, messages, completed, err)
+		}
+	}
+}
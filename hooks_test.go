@@ -0,0 +1,81 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithHooks(t *testing.T) {
+	errBad := errors.New("bad")
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		record("process " + msg.MessageId)
+		return errBad
+	}, WithHooks(Hooks{
+		BeforeMessage: func(ctx context.Context, msg events.SQSMessage) {
+			if ReceiveCount(ctx) != 2 {
+				t.Errorf("expected the hook's context to carry the receive count")
+			}
+			record("before " + msg.MessageId)
+		},
+	}), WithHooks(Hooks{
+		AfterMessage: func(ctx context.Context, msg events.SQSMessage, err error) {
+			if !errors.Is(err, errBad) {
+				t.Errorf("expected the processor's error, got %v", err)
+			}
+			record("after " + msg.MessageId)
+		},
+	}))
+
+	msg := testMessage("1")
+	msg.Attributes = map[string]string{"ApproximateReceiveCount": "2"}
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{msg})
+
+	expected := []string{"before 1", "process 1", "after 1"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("expected calls %v, got %v", expected, calls)
+			break
+		}
+	}
+}
+
+func TestWithBatchHooks(t *testing.T) {
+	var before, after int
+	var afterErr error
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "bad" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithHooks(Hooks{
+		BeforeBatch: func(ctx context.Context, messages []events.SQSMessage) {
+			before += len(messages)
+		},
+		AfterBatch: func(ctx context.Context, messages []events.SQSMessage, completed int, err error) {
+			after, afterErr = completed, err
+		},
+	}), WithLogger(nil))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("good"), testMessage("bad")})
+
+	var batchErr *BatchError
+	if before != 2 || after != 1 || !errors.As(afterErr, &batchErr) {
+		t.Errorf("expected the batch hooks to see the batch and its result, got %d, %d and %v", before, after, afterErr)
+	}
+}
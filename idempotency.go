@@ -0,0 +1,55 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// IdempotencyStore records which messages have been processed so that redelivered
+// messages aren't processed again, even by a different invocation.
+type IdempotencyStore interface {
+	// Seen reports whether the key has been marked as processed and not yet expired.
+	Seen(ctx context.Context, key string) (bool, error)
+	// MarkProcessed records the key as processed for the given amount of time.
+	MarkProcessed(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// idempotency configures how the handler uses an IdempotencyStore.
+type idempotency struct {
+	store IdempotencyStore
+	ttl   time.Duration
+	key   DedupKey
+}
+
+// processIdempotent runs the processor for a message unless the idempotency store has
+// seen it before, marking it as processed if it completes.  A message that can't be
+// checked against the store is left for redelivery.
+func (s *Handler) processIdempotent(ctx context.Context, msg events.SQSMessage) error {
+	key := s.idempotency.key(msg)
+	if key == "" {
+		return s.execute(ctx, msg)
+	}
+
+	seen, err := s.idempotency.store.Seen(ctx, key)
+	if err != nil {
+		return Transient(fmt.Errorf("failed to check idempotency of message %s: %w", msg.MessageId, err))
+	}
+	if seen {
+		s.logMessage(ctx, "message %s has already been processed, skipping", msg.MessageId)
+		return nil
+	}
+
+	if err := s.execute(ctx, msg); err != nil {
+		return err
+	}
+
+	// the work has been done by now, so failing to record it shouldn't see it done again
+	if err := s.idempotency.store.MarkProcessed(afterProcessing(ctx), key, s.idempotency.ttl); err != nil {
+		s.logMessage(ctx, "failed to mark message %s as processed: %v", msg.MessageId, err)
+	}
+
+	return nil
+}
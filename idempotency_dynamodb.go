@@ -0,0 +1,101 @@
+package sqsworker
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// PartialDynamoDBClient is a partial DynamoDB client that can read and write items.
+type PartialDynamoDBClient interface {
+	GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error)
+	PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error)
+}
+
+// DynamoDBIdempotencyStore is an IdempotencyStore backed by a DynamoDB table whose
+// partition key is a string.  Enable TTL on the table using TTLAttribute so that
+// expired keys are cleaned up; keys that have expired but not yet been removed are
+// treated as unseen.
+type DynamoDBIdempotencyStore struct {
+	Client    PartialDynamoDBClient
+	TableName string
+	// KeyAttribute is the name of the table's partition key.
+	KeyAttribute string
+	// TTLAttribute is the name of the attribute holding each key's expiry time, in
+	// epoch seconds.
+	TTLAttribute string
+}
+
+// NewDynamoDBIdempotencyStore creates a store using the given table, with a partition
+// key named "id" and TTL attribute named "expiresAt".
+func NewDynamoDBIdempotencyStore(client PartialDynamoDBClient, tableName string) *DynamoDBIdempotencyStore {
+	return &DynamoDBIdempotencyStore{
+		Client:       client,
+		TableName:    tableName,
+		KeyAttribute: "id",
+		TTLAttribute: "expiresAt",
+	}
+}
+
+// Seen implements the IdempotencyStore interface.
+func (d *DynamoDBIdempotencyStore) Seen(ctx context.Context, key string) (bool, error) {
+	out, err := d.Client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.TableName),
+		Key:            map[string]*dynamodb.AttributeValue{d.KeyAttribute: {S: aws.String(key)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if out.Item == nil {
+		return false, nil
+	}
+
+	// items without an expiry never expire
+	expiry, ok := out.Item[d.TTLAttribute]
+	if !ok || expiry.N == nil {
+		return true, nil
+	}
+
+	expiresAt, err := strconv.ParseInt(*expiry.N, 10, 64)
+	if err != nil {
+		return true, nil
+	}
+
+	return time.Now().Unix() < expiresAt, nil
+}
+
+// MarkProcessed implements the IdempotencyStore interface.  The write is conditional
+// so that an unexpired key is never overwritten, and a key written concurrently by
+// another invocation isn't treated as an error.
+func (d *DynamoDBIdempotencyStore) MarkProcessed(ctx context.Context, key string, ttl time.Duration) error {
+	now := time.Now()
+
+	_, err := d.Client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.TableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			d.KeyAttribute: {S: aws.String(key)},
+			d.TTLAttribute: {N: aws.String(strconv.FormatInt(now.Add(ttl).Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #ttl < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key": aws.String(d.KeyAttribute),
+			"#ttl": aws.String(d.TTLAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+
+	return err
+}
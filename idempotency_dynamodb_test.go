@@ -0,0 +1,72 @@
+package sqsworker
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// fakeDynamoDBClient is a PartialDynamoDBClient backed by a map, honouring the
+// store's conditional write.
+type fakeDynamoDBClient struct {
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (f *fakeDynamoDBClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.items[*input.Key["id"].S]}, nil
+}
+
+func (f *fakeDynamoDBClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	key := *input.Item["id"].S
+	if existing, ok := f.items[key]; ok {
+		expiresAt, _ := strconv.ParseInt(*existing["expiresAt"].N, 10, 64)
+		now, _ := strconv.ParseInt(*input.ExpressionAttributeValues[":now"].N, 10, 64)
+		if expiresAt >= now {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil)
+		}
+	}
+	f.items[key] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestDynamoDBIdempotencyStore(t *testing.T) {
+	client := &fakeDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	store := NewDynamoDBIdempotencyStore(client, "idempotency")
+	ctx := context.Background()
+
+	if seen, err := store.Seen(ctx, "1"); seen || err != nil {
+		t.Fatalf("expected an unknown key to be unseen, got %v and %v", seen, err)
+	}
+
+	if err := store.MarkProcessed(ctx, "1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if seen, _ := store.Seen(ctx, "1"); !seen {
+		t.Error("expected the key to be seen once marked")
+	}
+
+	if err := store.MarkProcessed(ctx, "1", time.Hour); err != nil {
+		t.Errorf("expected marking a key twice not to fail, got %v", err)
+	}
+}
+
+func TestDynamoDBIdempotencyStoreExpired(t *testing.T) {
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	client := &fakeDynamoDBClient{items: map[string]map[string]*dynamodb.AttributeValue{
+		"1": {"id": {S: aws.String("1")}, "expiresAt": {N: aws.String(expired)}},
+	}}
+	store := NewDynamoDBIdempotencyStore(client, "idempotency")
+
+	if seen, _ := store.Seen(context.Background(), "1"); seen {
+		t.Error("expected an expired key to be unseen")
+	}
+	if err := store.MarkProcessed(context.Background(), "1", time.Hour); err != nil {
+		t.Errorf("expected an expired key to be overwritten, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// memoryIdempotencyStore is an in-memory IdempotencyStore.
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	keys    map[string]time.Duration
+	seenErr error
+}
+
+func (m *memoryIdempotencyStore) Seen(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.keys[key]
+	return ok, m.seenErr
+}
+
+func (m *memoryIdempotencyStore) MarkProcessed(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.keys[key] = ttl
+	return nil
+}
+
+func TestWithIdempotencyStore(t *testing.T) {
+	client := &fakeSQSClient{}
+	store := &memoryIdempotencyStore{keys: map[string]time.Duration{"done": time.Hour}}
+	var calls atomic.Int32
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		calls.Add(1)
+		if msg.MessageId == "bad" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithIdempotencyStore(store, 24*time.Hour, nil))
+
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		testMessage("done"),
+		testMessage("new"),
+		testMessage("bad"),
+	})
+
+	if completed != 2 || calls.Load() != 2 {
+		t.Errorf("expected the seen message to skip the processor, got %d completed after %d calls", completed, calls.Load())
+	}
+	if store.keys["new"] != 24*time.Hour {
+		t.Errorf("expected the new message to be marked as processed, got %v", store.keys)
+	}
+	if _, ok := store.keys["bad"]; ok {
+		t.Error("expected the failed message not to be marked as processed")
+	}
+}
+
+func TestWithIdempotencyStoreUnavailable(t *testing.T) {
+	client := &fakeSQSClient{}
+	store := &memoryIdempotencyStore{keys: map[string]time.Duration{}, seenErr: errors.New("throttled")}
+	calls := 0
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		calls++
+		return nil
+	}, WithIdempotencyStore(store, time.Hour, nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if err == nil || completed != 0 || calls != 0 {
+		t.Errorf("expected the message to be left when the store can't be checked, got %d calls", calls)
+	}
+}
@@ -0,0 +1,79 @@
+package sqsworker
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// TestLocalStack runs a message through a real queue on LocalStack, or anything else
+// that speaks the SQS API, at the endpoint in SQSWORKER_TEST_ENDPOINT
+// (e.g. "http://localhost:4566").  It's skipped when the variable isn't set.
+func TestLocalStack(t *testing.T) {
+	endpoint := os.Getenv("SQSWORKER_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("SQSWORKER_TEST_ENDPOINT isn't set")
+	}
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(endpoint).
+		WithCredentials(credentials.NewStaticCredentials("test", "test", ""))))
+	client := sqs.New(sess)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	queue, err := client.CreateQueueWithContext(ctx, &sqs.CreateQueueInput{
+		QueueName: aws.String("sqsworker-test-" + time.Now().Format("20060102150405")),
+	})
+	if err != nil {
+		t.Fatalf("failed to create queue: %v", err)
+	}
+	defer client.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: queue.QueueUrl})
+
+	if _, err := client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:    queue.QueueUrl,
+		MessageBody: aws.String("hello"),
+	}); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+
+	pollCtx, stop := context.WithCancel(ctx)
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		defer stop()
+		if msg.Body != "hello" {
+			t.Errorf("unexpected body %q", msg.Body)
+		}
+		return nil
+	})
+
+	poller := NewPoller(client, *queue.QueueUrl, handler)
+	poller.WaitTime = time.Second
+	if err := poller.Run(pollCtx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	attrs, err := client.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl: queue.QueueUrl,
+		AttributeNames: aws.StringSlice([]string{
+			sqs.QueueAttributeNameApproximateNumberOfMessages,
+			sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+		}),
+	})
+	if err != nil {
+		t.Fatalf("failed to get queue attributes: %v", err)
+	}
+	for name, count := range attrs.Attributes {
+		if aws.StringValue(count) != "0" {
+			t.Errorf("expected the message to be deleted, but %s is %s", name, aws.StringValue(count))
+		}
+	}
+}
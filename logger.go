@@ -0,0 +1,121 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Logger is used by a Handler to report what happened to each message.  A *log.Logger
+// can be used as a Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// stdoutLogger is the default Logger, which prints each line to stdout for Lambda to
+// pass on to CloudWatch.
+type stdoutLogger struct{}
+
+func (stdoutLogger) Printf(format string, v ...interface{}) {
+	fmt.Printf(format+"\n", v...)
+}
+
+// discardLogger is a Logger that drops everything given to it.
+type discardLogger struct{}
+
+func (discardLogger) Printf(format string, v ...interface{}) {}
+
+// logField is a field attached to a message's log lines with LogWith.
+type logField struct {
+	key   string
+	value interface{}
+}
+
+// logFields holds the fields attached to a message's log lines.
+type logFields struct {
+	mu     sync.Mutex
+	fields []logField
+}
+
+// LogWith attaches key value pairs to the log lines the handler writes about the
+// message being processed, including the line written once it's finished with, so
+// that processors and middleware can correlate their own logs with the handler's.
+// Setting a key again replaces its value.  It does nothing if the context didn't
+// come from a Handler.
+func LogWith(ctx context.Context, keyvals ...interface{}) {
+	lf, ok := ctx.Value(logFieldsKey).(*logFields)
+	if !ok {
+		return
+	}
+
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+next:
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		var value interface{} = "(missing)"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+
+		for j := range lf.fields {
+			if lf.fields[j].key == key {
+				lf.fields[j].value = value
+				continue next
+			}
+		}
+		lf.fields = append(lf.fields, logField{key: key, value: value})
+	}
+}
+
+// withLogFields gives the context somewhere for LogWith to attach fields.
+func withLogFields(ctx context.Context) context.Context {
+	return context.WithValue(ctx, logFieldsKey, &logFields{})
+}
+
+// Logf writes a line to the handler's logger about the message being processed, with
+// the message's correlation ID and any fields attached with LogWith, so that middleware
+// logs go wherever WithLogger sends the handler's own.  It does nothing if the context
+// didn't come from a Handler.
+func Logf(ctx context.Context, format string, v ...interface{}) {
+	if s, ok := ctx.Value(loggerKey).(*Handler); ok {
+		s.logMessage(ctx, format, v...)
+	}
+}
+
+// withLogger lets Logf write to the handler's logger.
+func (s *Handler) withLogger(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loggerKey, s)
+}
+
+// logMessage logs a line about the message being processed, followed by the message's
+// correlation ID and any fields attached with LogWith.
+func (s *Handler) logMessage(ctx context.Context, format string, v ...interface{}) {
+	if id := CorrelationID(ctx); id != "" {
+		format += " correlationId=%s"
+		v = append(v, id)
+	}
+
+	if lf, ok := ctx.Value(logFieldsKey).(*logFields); ok {
+		lf.mu.Lock()
+		for _, f := range lf.fields {
+			format += " %s=%s"
+			v = append(v, f.key, formatLogValue(f.value))
+		}
+		lf.mu.Unlock()
+	}
+
+	s.logger.Printf(format, v...)
+}
+
+// formatLogValue formats a field's value, quoting it if it would be ambiguous otherwise.
+func formatLogValue(value interface{}) string {
+	str := fmt.Sprint(value)
+	if str == "" || strings.ContainsAny(str, " =\"\t\n") {
+		return strconv.Quote(str)
+	}
+	return str
+}
@@ -0,0 +1,80 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return Permanent(errors.New("bad payload"))
+	}, WithLogger(log.New(&buf, "", 0)))
+
+	handler.Handle(context.Background(), events.SQSEvent{Records: []events.SQSMessage{testMessage("1")}})
+
+	expected := "message 1 failed permanently: bad payload\nmessage 1 closed\n1 message(s) received, 1 closed\n"
+	if buf.String() != expected {
+		t.Errorf("expected log output %q, got %q", expected, buf.String())
+	}
+}
+
+func TestWithNilLogger(t *testing.T) {
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithLogger(nil))
+
+	if err := handler.Handle(context.Background(), events.SQSEvent{Records: []events.SQSMessage{testMessage("1")}}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestLogWith(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "1" {
+			LogWith(ctx, "orderID", 42, "customer", "Jo Bloggs")
+			LogWith(ctx, "orderID", 43, "dangling")
+		}
+		return errors.New("out of stock")
+	}, WithLogger(log.New(&buf, "", 0)))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	expected := "message 1 not closed: out of stock orderID=43 customer=\"Jo Bloggs\" dangling=(missing)\n"
+	if buf.String() != expected {
+		t.Errorf("expected log output %q, got %q", expected, buf.String())
+	}
+
+	// fields are only kept for the message they were attached to
+	buf.Reset()
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("2")})
+	if expected := "message 2 not closed: out of stock\n"; buf.String() != expected {
+		t.Errorf("expected log output %q, got %q", expected, buf.String())
+	}
+
+	LogWith(context.Background(), "ignored", true)
+}
+
+func TestLogf(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		LogWith(ctx, "orderID", 42)
+		Logf(ctx, "checking stock for message %s", msg.MessageId)
+		return errors.New("out of stock")
+	}, WithLogger(log.New(&buf, "", 0)))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if !strings.HasPrefix(buf.String(), "checking stock for message 1 orderID=42\n") {
+		t.Errorf("expected the line to go to the handler's logger with its fields, got %q", buf.String())
+	}
+
+	Logf(context.Background(), "ignored")
+}
@@ -3,87 +3,454 @@ package sqsworker
 import (
 	"context"
 	"errors"
-	"fmt"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"golang.org/x/time/rate"
 )
 
 // PartialSQSClient is an interface that describes a partial interface for an SQS client
-// that can be used to delete messages.
+// that can be used to delete messages and change their visibility.  Clients that also
+// implement PartialSQSBatchDeleter are used for WithBatchDelete, and ones that implement
+// PartialSQSSender for dead-letter queues and forwarding.
 type PartialSQSClient interface {
 	DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+// PartialSQSBatchDeleter is a partial SQS client that can delete messages in batches.
+// Without it, WithBatchDelete deletes the messages one at a time.
+type PartialSQSBatchDeleter interface {
+	DeleteMessageBatch(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error)
+}
+
+// PartialSQSSender is a partial SQS client that can send messages, for dead-letter
+// queues, forwarding and Requeue.
+type PartialSQSSender interface {
+	SendMessage(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error)
 }
 
 // MessageProcessor is a function that will handle a single SQS message from a batch.
 type MessageProcessor func(ctx context.Context, msg events.SQSMessage) error
 
+// ProcessSQSMessage calls the function, so that a MessageProcessor is also a Processor.
+func (p MessageProcessor) ProcessSQSMessage(ctx context.Context, msg events.SQSMessage) error {
+	return p(ctx, msg)
+}
+
+// Processor is implemented by types that handle SQS messages, for processors with
+// dependencies, such as database pools or caches, that are better built once and kept
+// across warm invocations than captured by a closure.
+type Processor interface {
+	ProcessSQSMessage(ctx context.Context, msg events.SQSMessage) error
+}
+
 // Handler is used for creating Lambdas that can process batches of SQS events.
 type Handler struct {
-	sqsClient PartialSQSClient
-	process   MessageProcessor
+	sqsClient    PartialSQSClient
+	process      MessageProcessor
+	retry        RetryPolicy
+	classify     ErrorClassifier
+	deadLetter   string
+	maxReceive   int
+	heartbeat    heartbeat
+	batchDelete  bool
+	resolver     QueueURLResolver
+	endpoint     string
+	fifo         bool
+	dedup        DedupKey
+	idempotency  idempotency
+	middleware   []Middleware
+	limiter      *rate.Limiter
+	breaker      *circuitBreaker
+	margin       time.Duration
+	concurrency  int
+	logger       Logger
+	hooks        []Hooks
+	captureDir   string
+	tasks        taskCallback
+	publish      publisher
+	forward      string
+	archive      *archiver
+	correlation  correlation
+	dryRun       dryRun
+	deletePolicy DeletePolicy
+	failFast     bool
+	sequential   bool
+	metrics      MetricsRecorder
+	shadow       *shadow
+	notifier     FailureNotifier
+
+	batchMiddleware []BatchMiddleware
+	batch           BatchHandlerFunc
+	batchResponse   bool
+	messageTimeout  time.Duration
+	chunkSize       int
+
+	// state kept across warm invocations
+	pool          *workerPool
+	slots         chan struct{}
+	deleteBuffers sync.Pool
 }
 
 // NewHandler creates an Handler instance using an SQS client instance and the
-// processing function that handles the each message.
-func NewHandler(sqsClient PartialSQSClient, processor MessageProcessor) *Handler {
-	return &Handler{
+// processing function that handles the each message.  Any options given are
+// applied in order.  Unless WithQueueURLResolver is given, queue URLs are built from
+// their ARNs, using the endpoint from WithEndpoint or, if the client is an SDK client with
+// a custom endpoint such as LocalStack, the client's endpoint.
+func NewHandler(sqsClient PartialSQSClient, processor MessageProcessor, opts ...Option) *Handler {
+	s := &Handler{
 		sqsClient: sqsClient,
 		process:   processor,
+		logger:    stdoutLogger{},
+		metrics:   noopMetrics{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.endpoint == "" {
+		s.endpoint = clientEndpoint(sqsClient)
+	}
+	switch r := s.resolver.(type) {
+	case nil:
+		s.resolver = ARNQueueURLResolver{Endpoint: s.endpoint}
+	case *cachingQueueURLResolver:
+		s.resolver = r.withEndpoint(s.endpoint)
+	}
+
+	s.process = chain(s.process, s.middleware)
+	s.batch = chainBatch(s.processMessages, s.batchMiddleware)
+	s.pool = newWorkerPool(s.runJob)
+	s.deleteBuffers.New = newDeleteBuffer
+	if s.concurrency > 0 {
+		s.slots = make(chan struct{}, s.concurrency)
+	}
+	if s.archive != nil {
+		s.archive.logger = s.logger
+	}
+	s.applyDryRun()
+
+	return s
+}
+
+// NewHandlerWithOptions is the same as NewHandler, for code that prefers to make it
+// clear that options are being given.
+func NewHandlerWithOptions(sqsClient PartialSQSClient, processor MessageProcessor, opts ...Option) *Handler {
+	return NewHandler(sqsClient, processor, opts...)
+}
+
+// NewProcessorHandler is the same as NewHandler for a Processor rather than a function.
+func NewProcessorHandler(sqsClient PartialSQSClient, processor Processor, opts ...Option) *Handler {
+	return NewHandler(sqsClient, processor.ProcessSQSMessage, opts...)
+}
+
+// outcome is the result of handling a single message from a batch.
+type outcome struct {
+	msg events.SQSMessage
+	// err is nil once the message has been closed
+	err error
+	// pending is set when the message is waiting to be deleted with the rest of the batch
+	pending bool
+	// retained is set when the message was closed but left for Lambda to delete
+	retained bool
+	// failure is what a message that was closed anyway failed with, when it was
+	// dead-lettered, dropped or deleted by the delete policy
+	failure error
+}
+
+// failed returns what the message failed with, whether or not it was closed anyway.
+func (res outcome) failed() error {
+	if res.err != nil {
+		return res.err
 	}
+	return res.failure
 }
 
 // handleMessage will handle a single SQS message from the batch provided.  If the message
-// is able to be completed, then it will attempt to delete the message from SQS.
-func (s *Handler) handleMessage(ctx context.Context, ch chan error, msg events.SQSMessage) {
-	// process the message using the provided processor
-	err := s.process(ctx, msg)
+// is able to be completed, or has failed permanently, then it will attempt to delete the
+// message from SQS, forwarding permanent failures to the dead-letter queue first if one is
+// configured.  Messages that asked to be retried after a delay have their visibility timeout
+// changed accordingly.
+func (s *Handler) handleMessage(ctx context.Context, msg events.SQSMessage) outcome {
+	// don't start on messages there's no longer time for
+	if ctx.Err() != nil {
+		s.metrics.MessageFailed(s.queueLabel(msg.EventSourceARN))
+		return outcome{msg: msg, err: notStartedError(ctx)}
+	}
+
+	ctx = s.withLogger(withLogFields(withOutput(withReceiveCount(ctx, receiveCount(msg)))))
+	ctx = s.withCorrelationID(ctx, msg)
+	s.beforeMessage(ctx, msg)
+	s.archive.received(ctx, msg)
+	s.startShadow(ctx, msg)
+
+	start := time.Now()
+	res := s.closeMessage(ctx, msg)
+	failBatch(ctx, res)
+	s.recordOutcome(msg, res, time.Since(start))
+	s.archive.handled(ctx, msg, res.failed())
+	s.afterMessage(ctx, msg, res.err)
+
+	switch {
+	case res.err != nil:
+		s.logMessage(ctx, "message %s not closed: %v", msg.MessageId, res.err)
+	case res.pending:
+		s.logMessage(ctx, "message %s completed, waiting to be deleted", msg.MessageId)
+	case res.retained:
+		s.logMessage(ctx, "message %s completed, leaving it for Lambda to delete", msg.MessageId)
+	default:
+		s.logMessage(ctx, "message %s closed", msg.MessageId)
+	}
+
+	return res
+}
+
+// closeMessage processes a message and then closes it according to the result.
+func (s *Handler) closeMessage(ctx context.Context, msg events.SQSMessage) outcome {
+	// process the message using the provided processor, unless it's already failed too many
+	// times or has been processed before
+	var err error
+	if s.maxReceive > 0 && ReceiveCount(ctx) > s.maxReceive {
+		err = Permanent(ErrPoisonMessage)
+	} else if s.idempotency.store != nil {
+		err = s.processIdempotent(ctx, msg)
+	} else {
+		err = s.execute(ctx, msg)
+	}
+
+	// the message is closed even once the deadline margin has stopped processing
+	closeCtx := afterProcessing(ctx)
+
+	// pass on the result of messages that were processed, which can fail in the same ways
+	if err == nil {
+		err = s.completeMessage(closeCtx, msg)
+	}
+
+	// messages cancelled by another message failing are left as they are
+	if err, ok := cancelledError(ctx, err); ok {
+		return outcome{msg: msg, err: err}
+	}
+
+	var failure error
+	if err != nil {
+		if classifyError(s.classify, err) == ClassPermanent {
+			// permanent failures will never succeed, so there's no point in leaving them on the queue
+			s.logMessage(ctx, "message %s failed permanently: %v", msg.MessageId, err)
+			s.failTask(closeCtx, msg, err)
+			cause := err
+			if err = s.sendToDeadLetter(closeCtx, msg, cause); err == nil {
+				failure = cause
+				s.notifyPermanentFailure(closeCtx, msg, cause)
+			}
+		} else if delay, ok := retryDelay(err); ok && fromSQS(msg) {
+			s.changeVisibility(closeCtx, msg, delay)
+		}
+	}
+
+	if err != nil && s.deletePolicy == DeleteAlways {
+		s.logMessage(ctx, "message %s failed, deleting it anyway: %v", msg.MessageId, err)
+		failure, err = err, nil
+	}
 
 	// if we've reached this point with no error, then let's try and remove the message from SQS
+	// unless it came from another event source, which Lambda keeps track of itself
 	if err == nil {
-		queueURL := convertARN2URL(msg.EventSourceARN)
+		if s.deletePolicy == DeleteNever || !fromSQS(msg) {
+			return outcome{msg: msg, retained: true, failure: failure}
+		}
+		if s.batchDelete {
+			return outcome{msg: msg, pending: true, failure: failure}
+		}
+
+		err = s.deleteMessage(msg)
+	}
+
+	return outcome{msg: msg, err: err, failure: failure}
+}
 
-		_, err = s.sqsClient.DeleteMessage(&sqs.DeleteMessageInput{
-			ReceiptHandle: &msg.ReceiptHandle,
-			QueueUrl:      &queueURL,
-		})
+// completeMessage passes on the result of a message that was processed successfully.
+// Errors are classified like the processor's, so a transient error leaves the message on
+// the queue to be processed again, and a permanent one dead-letters it.
+//
+// Each step happens at least once, as a message that fails part way through is processed
+// and completed again.  Publishing comes last, so that it's only repeated when the
+// publish itself fails.
+func (s *Handler) completeMessage(ctx context.Context, msg events.SQSMessage) error {
+	if err := s.forwardMessage(ctx, msg); err != nil {
+		return err
 	}
+	if err := s.succeedTask(ctx, msg); err != nil {
+		return err
+	}
+
+	return s.publishOutput(ctx, msg)
+}
+
+// changeVisibility makes the message visible on the queue again once the given
+// delay has passed.
+func (s *Handler) changeVisibility(ctx context.Context, msg events.SQSMessage, delay time.Duration) {
+	queueURL, err := s.queueURL(msg.EventSourceARN)
+	if err != nil {
+		s.logMessage(ctx, "failed to change visibility of message %s: %v", msg.MessageId, err)
+		return
+	}
+	timeout := visibilityTimeout(delay)
+
+	_, err = s.sqsClient.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		ReceiptHandle:     &msg.ReceiptHandle,
+		QueueUrl:          &queueURL,
+		VisibilityTimeout: &timeout,
+	})
+	if err != nil {
+		s.logMessage(ctx, "failed to change visibility of message %s: %v", msg.MessageId, err)
+	}
+}
 
-	ch <- err
+// execute runs the processor for a message while keeping its visibility timeout extended.
+func (s *Handler) execute(ctx context.Context, msg events.SQSMessage) error {
+	stop := s.startHeartbeat(ctx, msg)
+	defer stop()
+
+	return s.runWithTimeout(ctx, msg)
+}
+
+// runProcessor invokes the processor for a single message, retrying any transient
+// failures according to the handler's retry policy.
+func (s *Handler) runProcessor(ctx context.Context, msg events.SQSMessage) error {
+	err := s.invoke(ctx, msg)
+
+	for attempt := 1; err != nil && s.retry != nil; attempt++ {
+		if classifyError(s.classify, err) != ClassTransient {
+			break
+		}
+
+		delay, ok := s.retry.Backoff(attempt, err)
+		if !ok || !sleep(ctx, delay) {
+			break
+		}
+
+		err = s.invoke(ctx, msg)
+	}
+
+	return err
 }
 
 // ProcessMessages handles a batch of SQS messages and returns the total number of
-// successfully processed messages and any error that has occurred.
+// successfully processed messages.  If any messages couldn't be completed, the error
+// is a *BatchError describing each of them.
 func (s *Handler) ProcessMessages(ctx context.Context, messages []events.SQSMessage) (completed int, err error) {
-	count := len(messages)
+	return s.batch(ctx, messages)
+}
+
+// processMessages is the BatchHandlerFunc wrapped by any batch middleware.
+func (s *Handler) processMessages(ctx context.Context, messages []events.SQSMessage) (completed int, err error) {
+	s.beforeBatch(ctx, messages)
+	s.capture(messages)
+
+	outcomes := s.processChunks(ctx, messages)
+	for _, res := range outcomes {
+		if res.err == nil {
+			completed++
+		}
+	}
+	err = newBatchError(len(messages), outcomes)
+	s.notifyFailures(ctx, outcomes)
+
+	s.afterBatch(ctx, messages, completed, err)
+	return completed, err
+}
 
+// processBatch handles a batch of SQS messages and returns the outcome of each one,
+// in the order they finished.
+func (s *Handler) processBatch(ctx context.Context, messages []events.SQSMessage) []outcome {
 	// check to see if there are any messages and report if there are none
-	if count == 0 {
-		return
+	if len(messages) == 0 {
+		return nil
+	}
+
+	for _, msg := range messages {
+		s.metrics.MessageReceived(s.queueLabel(msg.EventSourceARN))
+	}
+
+	// stop processing in time to tidy up before Lambda's deadline
+	ctx, cancel := s.withDeadlineMargin(withInvocation(ctx))
+	defer cancel()
+
+	// allow the rest of the batch to be cancelled if a message fails
+	if s.failFast {
+		var cancelBatch context.CancelCauseFunc
+		ctx, cancelBatch = withFailFast(ctx)
+		defer cancelBatch(nil)
+	}
+
+	// hold back any duplicates so that each message is only processed once
+	unique, duplicates := messages, map[string][]events.SQSMessage(nil)
+	if s.dedup != nil {
+		unique, duplicates = deduplicate(messages, s.dedup)
 	}
 
 	// create a buffered channel for handling processed messages
-	results := make(chan error, count)
+	results := make(chan outcome, len(unique))
 
-	// process the messages in parallel
-	for _, message := range messages {
-		go s.handleMessage(ctx, results, message)
+	// hand the messages to the handler's workers, limited to however many are allowed
+	// at once
+	submit := func(group []events.SQSMessage) {
+		s.acquire()
+		s.pool.submit(poolJob{ctx: ctx, group: group, results: results})
 	}
 
-	// wait on the processed messages and tally the results
-	for i := 0; i < count; i++ {
-		err := <-results
-		if err == nil {
-			completed++
+	switch {
+	case s.sequential:
+		// process the whole batch in order, as though it were a single message group
+		submit(unique)
+	case s.fifo:
+		// process each message group in parallel, but the messages within it in order
+		for _, group := range groupMessages(unique) {
+			submit(group)
+		}
+	default:
+		// process the messages in parallel
+		for i := range unique {
+			submit(unique[i : i+1])
+		}
+	}
+
+	// wait on the processed messages and collect the results
+	outcomes := make([]outcome, 0, len(messages))
+	var pending []events.SQSMessage
+	for i := 0; i < len(unique); i++ {
+		res := <-results
+		outcomes, pending = collectOutcome(outcomes, pending, res)
+		for _, dup := range s.settleDuplicates(res, duplicates[res.msg.ReceiptHandle]) {
+			outcomes, pending = collectOutcome(outcomes, pending, dup)
 		}
 	}
 
-	if completed != count {
-		err = errors.New("failed to complete all given messages")
+	// delete any messages that were left for a batch delete
+	for i, err := range s.deleteMessages(pending) {
+		outcomes = append(outcomes, outcome{msg: pending[i], err: err})
 	}
 
-	return
+	// make sure the batch is archived, its shadows finished and its metrics sent before
+	// Lambda freezes the function
+	s.archive.wait()
+	s.shadow.wait()
+	s.flushMetrics(ctx)
+
+	return outcomes
+}
+
+// collectOutcome adds an outcome to those of the batch, or to the messages waiting to be
+// deleted if it's pending.
+func collectOutcome(outcomes []outcome, pending []events.SQSMessage, res outcome) ([]outcome, []events.SQSMessage) {
+	if res.pending {
+		return outcomes, append(pending, res.msg)
+	}
+	return append(outcomes, res), pending
 }
 
 // Handle is the method responsible for processing each batch of messages for
@@ -92,18 +459,36 @@ func (s *Handler) Handle(ctx context.Context, ev events.SQSEvent) error {
 	completed, err := s.ProcessMessages(ctx, ev.Records)
 
 	// print a status message to our logs
-	fmt.Printf("%d message(s) received, %d closed\n", len(ev.Records), completed)
+	s.logger.Printf("%d message(s) received, %d closed", len(ev.Records), completed)
 
 	return err
 }
 
-// convertARN2URL converts the ARN of an SQS queue to the URL version.
-func convertARN2URL(arn string) string {
-	parts := strings.Split(arn, ":")
-	return "https://" + parts[2] + "." + parts[3] + ".amazonaws.com/" + parts[4] + "/" + parts[5]
-}
+// HandleWithResponse is an alternative to Handle for event source mappings with
+// ReportBatchItemFailures enabled.  Rather than failing the whole invocation, it
+// reports each message that wasn't completed as a batch item failure so that only
+// those messages are retried.
+func (s *Handler) HandleWithResponse(ctx context.Context, ev events.SQSEvent) (events.SQSEventResponse, error) {
+	var response events.SQSEventResponse
+	completed, err := s.ProcessMessages(ctx, ev.Records)
+
+	// print a status message to our logs
+	s.logger.Printf("%d message(s) received, %d closed", len(ev.Records), completed)
+
+	// anything other than failed messages, such as batch middleware failing, fails the
+	// whole batch
+	var batchErr *BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		return response, err
+	}
+
+	if batchErr != nil {
+		for _, msgErr := range batchErr.Errors {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: msgErr.MessageID,
+			})
+		}
+	}
 
-// GetURLFromMessage converts the ARN for an SQS message to the queue URL.
-func GetURLFromMessage(msg events.SQSMessage) string {
-	return convertARN2URL(msg.EventSourceARN)
+	return response, nil
 }
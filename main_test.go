@@ -1,6 +1,108 @@
 package sqsworker
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const testQueueARN = "arn:aws:sqs:us-west-2:123456:my_queue_name"
+
+// fakeSQSClient is a PartialSQSClient that records the calls made to it.
+type fakeSQSClient struct {
+	mu         sync.Mutex
+	deleted    []string
+	visibility map[string]int64
+	sent       []*sqs.SendMessageInput
+	sendErr    error
+	batches    int
+	// failDelete is the number of times deleting each receipt handle should fail
+	failDelete map[string]int
+	// failBatches is the number of DeleteMessageBatch calls that should fail outright
+	failBatches int
+}
+
+func (c *fakeSQSClient) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, *input.ReceiptHandle)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (c *fakeSQSClient) DeleteMessageBatch(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batches++
+	if c.failBatches > 0 {
+		c.failBatches--
+		return nil, errors.New("throttled")
+	}
+
+	out := &sqs.DeleteMessageBatchOutput{}
+	for _, entry := range input.Entries {
+		if c.failDelete[*entry.ReceiptHandle] > 0 {
+			c.failDelete[*entry.ReceiptHandle]--
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{
+				Id:          entry.Id,
+				Code:        aws.String("InternalError"),
+				Message:     aws.String("try again"),
+				SenderFault: aws.Bool(false),
+			})
+			continue
+		}
+		c.deleted = append(c.deleted, *entry.ReceiptHandle)
+		out.Successful = append(out.Successful, &sqs.DeleteMessageBatchResultEntry{Id: entry.Id})
+	}
+	return out, nil
+}
+
+func (c *fakeSQSClient) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.visibility == nil {
+		c.visibility = map[string]int64{}
+	}
+	c.visibility[*input.ReceiptHandle] = *input.VisibilityTimeout
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (c *fakeSQSClient) SendMessage(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sendErr != nil {
+		return nil, c.sendErr
+	}
+	c.sent = append(c.sent, input)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+// minimalSQSClient is a PartialSQSClient that can't delete in batches or send messages.
+type minimalSQSClient struct {
+	client *fakeSQSClient
+}
+
+func (c minimalSQSClient) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	return c.client.DeleteMessage(input)
+}
+
+func (c minimalSQSClient) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return c.client.ChangeMessageVisibility(input)
+}
+
+// testMessage creates a message from the test queue with the given ID.
+func testMessage(id string) events.SQSMessage {
+	return events.SQSMessage{
+		MessageId:      id,
+		ReceiptHandle:  "handle-" + id,
+		EventSourceARN: testQueueARN,
+	}
+}
 
 func TestNewHandler(t *testing.T) {
 
@@ -14,6 +116,62 @@ func TestProcessMessages(t *testing.T) {}
 
 func TestHandle(t *testing.T) {}
 
+func TestHandleWithResponse(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "bad" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	response, err := handler.HandleWithResponse(context.Background(), events.SQSEvent{
+		Records: []events.SQSMessage{testMessage("good"), testMessage("bad")},
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(response.BatchItemFailures) != 1 || response.BatchItemFailures[0].ItemIdentifier != "bad" {
+		t.Errorf("expected only the failed message to be reported, got %v", response.BatchItemFailures)
+	}
+}
+
+// orderProcessor is a Processor that records the messages it's given.
+type orderProcessor struct {
+	mu        sync.Mutex
+	processed []string
+}
+
+func (p *orderProcessor) ProcessSQSMessage(ctx context.Context, msg events.SQSMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processed = append(p.processed, msg.MessageId)
+	return nil
+}
+
+func TestNewProcessorHandler(t *testing.T) {
+	client, processor := &fakeSQSClient{}, &orderProcessor{}
+	handler := NewProcessorHandler(client, processor, WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+	if completed != 1 || err != nil || len(processor.processed) != 1 || len(client.deleted) != 1 {
+		t.Errorf("expected the processor to handle the message, got %d: %v", completed, err)
+	}
+}
+
+func TestMessageProcessorIsProcessor(t *testing.T) {
+	called := false
+	var processor Processor = MessageProcessor(func(ctx context.Context, msg events.SQSMessage) error {
+		called = true
+		return nil
+	})
+
+	if err := processor.ProcessSQSMessage(context.Background(), testMessage("1")); err != nil || !called {
+		t.Errorf("expected the function to be called, got %v", err)
+	}
+}
+
 func TestConvertARN2URL(t *testing.T) {
 	arn := "arn:aws:sqs:us-west-2:123456:my_queue_name"
 	expected := "https://sqs.us-west-2.amazonaws.com/123456/my_queue_name"
@@ -24,3 +182,37 @@ func TestConvertARN2URL(t *testing.T) {
 		t.Errorf("expected %v to equal %v", url, expected)
 	}
 }
+
+func TestWithMaxConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}, WithMaxConcurrency(2))
+
+	var messages []events.SQSMessage
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		messages = append(messages, testMessage(id))
+	}
+
+	completed, err := handler.ProcessMessages(context.Background(), messages)
+	if completed != len(messages) || err != nil {
+		t.Errorf("expected all messages to complete, got %d: %v", completed, err)
+	}
+	if peak != 2 {
+		t.Errorf("expected at most 2 messages at once, got %d", peak)
+	}
+}
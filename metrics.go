@@ -0,0 +1,72 @@
+package sqsworker
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// MetricsRecorder receives metrics about the messages a Handler works through, labelled
+// with the name of the queue each message came from.  Implementations must be safe for
+// concurrent use, as messages are handled in parallel.
+type MetricsRecorder interface {
+	// MessageReceived counts a message given to the handler.
+	MessageReceived(queue string)
+	// MessageSucceeded counts a message that was completed.
+	MessageSucceeded(queue string)
+	// MessageFailed counts a message that wasn't completed and has been left on the
+	// queue, or was dead-lettered, dropped or deleted anyway after failing.
+	MessageFailed(queue string)
+	// MessageDeleted counts a message deleted from its queue.
+	MessageDeleted(queue string)
+	// ObserveDuration records how long a message took to process and close.
+	ObserveDuration(queue string, d time.Duration)
+}
+
+// MetricsFlusher is implemented by recorders that buffer metrics, such as the
+// PrometheusRecorder.  Flush is called at the end of each batch, before Lambda freezes
+// the function.
+type MetricsFlusher interface {
+	Flush(ctx context.Context) error
+}
+
+// noopMetrics is the MetricsRecorder used when none is given.
+type noopMetrics struct{}
+
+func (noopMetrics) MessageReceived(string)                {}
+func (noopMetrics) MessageSucceeded(string)               {}
+func (noopMetrics) MessageFailed(string)                  {}
+func (noopMetrics) MessageDeleted(string)                 {}
+func (noopMetrics) ObserveDuration(string, time.Duration) {}
+
+// queueName returns the name of the queue with the given ARN, or the ARN itself if it
+// can't be parsed.
+func queueName(arn string) string {
+	if parsed, err := parseQueueARN(arn); err == nil {
+		return parsed.name
+	}
+	return arn
+}
+
+// recordOutcome records the outcome of a message and how long it took.
+func (s *Handler) recordOutcome(msg events.SQSMessage, res outcome, d time.Duration) {
+	queue := s.queueLabel(msg.EventSourceARN)
+	if res.failed() != nil {
+		s.metrics.MessageFailed(queue)
+	} else {
+		s.metrics.MessageSucceeded(queue)
+	}
+	s.metrics.ObserveDuration(queue, d)
+}
+
+// flushMetrics flushes the recorder if it buffers metrics.  Failures are only logged.
+func (s *Handler) flushMetrics(ctx context.Context) {
+	flusher, ok := s.metrics.(MetricsFlusher)
+	if !ok {
+		return
+	}
+	if err := flusher.Flush(context.WithoutCancel(ctx)); err != nil {
+		s.logger.Printf("failed to flush metrics: %v", err)
+	}
+}
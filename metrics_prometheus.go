@@ -0,0 +1,220 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of the processing duration
+// histogram buckets used by a PrometheusRecorder unless others are given.
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// PrometheusRecorder is a MetricsRecorder that keeps metrics in memory and pushes them to
+// a Prometheus Pushgateway as each batch finishes, as a Lambda function can't be scraped.
+// Values are totals for the life of the recorder, so each function instance should push
+// to its own grouping, such as one with an instance label.
+type PrometheusRecorder struct {
+	// Gateway is the base URL of the Pushgateway, such as http://pushgateway:9091.
+	Gateway string
+	// Job is the job label the metrics are pushed under.
+	Job string
+	// Grouping holds any further labels that make up the grouping key.
+	Grouping map[string]string
+	// Namespace is prefixed to each metric name, and defaults to sqsworker.
+	Namespace string
+	// Buckets are the histogram buckets, and default to DefaultDurationBuckets.
+	Buckets []float64
+	// Client is the HTTP client used to push, and defaults to http.DefaultClient.
+	Client *http.Client
+
+	mu        sync.Mutex
+	counters  map[string]map[string]float64
+	durations map[string]*durationHistogram
+}
+
+// durationHistogram is a cumulative histogram of processing durations for one queue.
+type durationHistogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusRecorder creates a PrometheusRecorder that pushes to the given
+// Pushgateway under the given job.
+func NewPrometheusRecorder(gateway, job string) *PrometheusRecorder {
+	return &PrometheusRecorder{Gateway: gateway, Job: job}
+}
+
+// MessageReceived implements MetricsRecorder.
+func (r *PrometheusRecorder) MessageReceived(queue string) {
+	r.inc("messages_received_total", queue)
+}
+
+// MessageSucceeded implements MetricsRecorder.
+func (r *PrometheusRecorder) MessageSucceeded(queue string) {
+	r.inc("messages_succeeded_total", queue)
+}
+
+// MessageFailed implements MetricsRecorder.
+func (r *PrometheusRecorder) MessageFailed(queue string) {
+	r.inc("messages_failed_total", queue)
+}
+
+// MessageDeleted implements MetricsRecorder.
+func (r *PrometheusRecorder) MessageDeleted(queue string) {
+	r.inc("messages_deleted_total", queue)
+}
+
+// ShadowSucceeded implements ShadowRecorder.
+func (r *PrometheusRecorder) ShadowSucceeded(queue string) {
+	r.inc("shadow_succeeded_total", queue)
+}
+
+// ShadowFailed implements ShadowRecorder.
+func (r *PrometheusRecorder) ShadowFailed(queue string) {
+	r.inc("shadow_failed_total", queue)
+}
+
+// ObserveDuration implements MetricsRecorder.
+func (r *PrometheusRecorder) ObserveDuration(queue string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.durations == nil {
+		r.durations = map[string]*durationHistogram{}
+	}
+	h, ok := r.durations[queue]
+	if !ok {
+		h = &durationHistogram{counts: make([]uint64, len(r.buckets()))}
+		r.durations[queue] = h
+	}
+
+	seconds := d.Seconds()
+	for i, bound := range r.buckets() {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Flush implements MetricsFlusher by pushing every metric to the Pushgateway,
+// replacing those previously pushed to the same grouping.
+func (r *PrometheusRecorder) Flush(ctx context.Context) error {
+	pushURL := strings.TrimSuffix(r.Gateway, "/") + "/metrics/job/" + url.PathEscape(r.Job)
+	for _, key := range sortedKeys(r.Grouping) {
+		pushURL += "/" + url.PathEscape(key) + "/" + url.PathEscape(r.Grouping[key])
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, pushURL, bytes.NewReader(r.exposition()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to push metrics: unexpected status %s", res.Status)
+	}
+	return nil
+}
+
+// inc adds one to a counter for a queue.
+func (r *PrometheusRecorder) inc(name, queue string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.counters == nil {
+		r.counters = map[string]map[string]float64{}
+	}
+	if r.counters[name] == nil {
+		r.counters[name] = map[string]float64{}
+	}
+	r.counters[name][queue]++
+}
+
+// exposition writes the metrics in the Prometheus text format.
+func (r *PrometheusRecorder) exposition() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, name := range sortedKeys(r.counters) {
+		fmt.Fprintf(&buf, "# TYPE %s counter\n", r.metricName(name))
+		for _, queue := range sortedKeys(r.counters[name]) {
+			fmt.Fprintf(&buf, "%s{queue=%s} %s\n", r.metricName(name), quoteLabel(queue), formatFloat(r.counters[name][queue]))
+		}
+	}
+
+	if len(r.durations) > 0 {
+		name := r.metricName("processing_duration_seconds")
+		fmt.Fprintf(&buf, "# TYPE %s histogram\n", name)
+		for _, queue := range sortedKeys(r.durations) {
+			h, label := r.durations[queue], quoteLabel(queue)
+			for i, bound := range r.buckets() {
+				fmt.Fprintf(&buf, "%s_bucket{queue=%s,le=\"%s\"} %d\n", name, label, formatFloat(bound), h.counts[i])
+			}
+			fmt.Fprintf(&buf, "%s_bucket{queue=%s,le=\"+Inf\"} %d\n", name, label, h.count)
+			fmt.Fprintf(&buf, "%s_sum{queue=%s} %s\n", name, label, formatFloat(h.sum))
+			fmt.Fprintf(&buf, "%s_count{queue=%s} %d\n", name, label, h.count)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// metricName adds the recorder's namespace to a metric name.
+func (r *PrometheusRecorder) metricName(name string) string {
+	namespace := r.Namespace
+	if namespace == "" {
+		namespace = "sqsworker"
+	}
+	return namespace + "_" + name
+}
+
+// buckets returns the histogram buckets in use.
+func (r *PrometheusRecorder) buckets() []float64 {
+	if len(r.Buckets) > 0 {
+		return r.Buckets
+	}
+	return DefaultDurationBuckets
+}
+
+// sortedKeys returns the keys of a map in order, so that pushes are consistent.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quoteLabel quotes a label value, escaping it as the text format needs.
+func quoteLabel(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return `"` + value + `"`
+}
+
+// formatFloat formats a sample value as briefly as possible.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
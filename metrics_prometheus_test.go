@@ -0,0 +1,62 @@
+package sqsworker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusRecorder(t *testing.T) {
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, body = r.URL.Path, string(data)
+	}))
+	defer server.Close()
+
+	recorder := NewPrometheusRecorder(server.URL, "worker")
+	recorder.Grouping = map[string]string{"instance": "a"}
+	recorder.Buckets = []float64{0.1, 1}
+
+	recorder.MessageReceived("orders")
+	recorder.MessageReceived("orders")
+	recorder.MessageSucceeded("orders")
+	recorder.ObserveDuration("orders", 500*time.Millisecond)
+
+	if err := recorder.Flush(context.Background()); err != nil {
+		t.Fatalf("expected the metrics to be pushed, got %v", err)
+	}
+	if path != "/metrics/job/worker/instance/a" {
+		t.Errorf("expected the metrics to be pushed to the grouping, got %s", path)
+	}
+
+	for _, expected := range []string{
+		"# TYPE sqsworker_messages_received_total counter\nsqsworker_messages_received_total{queue=\"orders\"} 2\n",
+		"sqsworker_messages_succeeded_total{queue=\"orders\"} 1\n",
+		"# TYPE sqsworker_processing_duration_seconds histogram\n",
+		"sqsworker_processing_duration_seconds_bucket{queue=\"orders\",le=\"0.1\"} 0\n",
+		"sqsworker_processing_duration_seconds_bucket{queue=\"orders\",le=\"1\"} 1\n",
+		"sqsworker_processing_duration_seconds_bucket{queue=\"orders\",le=\"+Inf\"} 1\n",
+		"sqsworker_processing_duration_seconds_sum{queue=\"orders\"} 0.5\n",
+		"sqsworker_processing_duration_seconds_count{queue=\"orders\"} 1\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected the push to contain %q, got %q", expected, body)
+		}
+	}
+}
+
+func TestPrometheusRecorderPushFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	if err := NewPrometheusRecorder(server.URL, "worker").Flush(context.Background()); err == nil {
+		t.Error("expected a failed push to be reported")
+	}
+}
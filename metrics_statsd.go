@@ -0,0 +1,107 @@
+package sqsworker
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DatadogExtensionAddr is the address the Datadog Lambda extension listens for
+// DogStatsD metrics on.
+const DatadogExtensionAddr = "127.0.0.1:8125"
+
+// StatsDRecorder is a MetricsRecorder that sends metrics to a StatsD server over UDP.
+// Plain StatsD has no tags, so the queue is part of each metric's name, as in
+// prefix.queue.messages.received.  Metrics that fail to send are dropped.
+type StatsDRecorder struct {
+	conn   net.Conn
+	prefix string
+	tags   bool
+}
+
+// NewStatsDRecorder creates a StatsDRecorder that sends to the server at addr, naming
+// every metric with the given prefix.
+func NewStatsDRecorder(addr, prefix string) (*StatsDRecorder, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to StatsD at %s: %w", addr, err)
+	}
+	return &StatsDRecorder{conn: conn, prefix: prefix}, nil
+}
+
+// NewDatadogRecorder creates a StatsDRecorder that sends DogStatsD metrics to the
+// Datadog Lambda extension, tagging them with the queue rather than naming it.
+func NewDatadogRecorder(prefix string) (*StatsDRecorder, error) {
+	r, err := NewStatsDRecorder(DatadogExtensionAddr, prefix)
+	if err != nil {
+		return nil, err
+	}
+	r.tags = true
+	return r, nil
+}
+
+// MessageReceived implements MetricsRecorder.
+func (r *StatsDRecorder) MessageReceived(queue string) {
+	r.send("messages.received", queue, "1|c")
+}
+
+// MessageSucceeded implements MetricsRecorder.
+func (r *StatsDRecorder) MessageSucceeded(queue string) {
+	r.send("messages.succeeded", queue, "1|c")
+}
+
+// MessageFailed implements MetricsRecorder.
+func (r *StatsDRecorder) MessageFailed(queue string) {
+	r.send("messages.failed", queue, "1|c")
+}
+
+// MessageDeleted implements MetricsRecorder.
+func (r *StatsDRecorder) MessageDeleted(queue string) {
+	r.send("messages.deleted", queue, "1|c")
+}
+
+// ShadowSucceeded implements ShadowRecorder.
+func (r *StatsDRecorder) ShadowSucceeded(queue string) {
+	r.send("shadow.succeeded", queue, "1|c")
+}
+
+// ShadowFailed implements ShadowRecorder.
+func (r *StatsDRecorder) ShadowFailed(queue string) {
+	r.send("shadow.failed", queue, "1|c")
+}
+
+// ObserveDuration implements MetricsRecorder with a timer in milliseconds, or a
+// histogram for DogStatsD.
+func (r *StatsDRecorder) ObserveDuration(queue string, d time.Duration) {
+	kind := "ms"
+	if r.tags {
+		kind = "h"
+	}
+	r.send("processing.duration", queue, fmt.Sprintf("%g|%s", float64(d)/float64(time.Millisecond), kind))
+}
+
+// Close closes the connection to the server.
+func (r *StatsDRecorder) Close() error {
+	return r.conn.Close()
+}
+
+// send writes a single metric in the StatsD line format.
+func (r *StatsDRecorder) send(name, queue, value string) {
+	var line string
+	if r.tags {
+		line = fmt.Sprintf("%s:%s|#queue:%s", r.metricName(name), value, queue)
+	} else {
+		line = fmt.Sprintf("%s:%s", r.metricName(queue+"."+name), value)
+	}
+	r.conn.Write([]byte(line))
+}
+
+// metricName adds the recorder's prefix to a metric name, replacing the characters the
+// line format uses as separators.
+func (r *StatsDRecorder) metricName(name string) string {
+	if r.prefix != "" {
+		name = r.prefix + "." + name
+	}
+	return strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_").Replace(name)
+}
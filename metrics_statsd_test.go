@@ -0,0 +1,74 @@
+package sqsworker
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// listenStatsD starts a UDP server for a StatsDRecorder to send to.
+func listenStatsD(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readStatsD reads the given number of metric lines from the server.
+func readStatsD(t *testing.T, conn net.PacketConn, n int) []string {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	var lines []string
+	buf := make([]byte, 1024)
+	for i := 0; i < n; i++ {
+		read, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("expected %d metrics, got %v: %v", n, lines, err)
+		}
+		lines = append(lines, string(buf[:read]))
+	}
+	return lines
+}
+
+func TestStatsDRecorder(t *testing.T) {
+	server := listenStatsD(t)
+	recorder, err := NewStatsDRecorder(server.LocalAddr().String(), "worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+
+	recorder.MessageReceived("orders")
+	recorder.MessageDeleted("orders")
+	recorder.ObserveDuration("orders", 1500*time.Microsecond)
+
+	expected := []string{"worker.orders.messages.received:1|c", "worker.orders.messages.deleted:1|c", "worker.orders.processing.duration:1.5|ms"}
+	for i, line := range readStatsD(t, server, 3) {
+		if line != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], line)
+		}
+	}
+}
+
+func TestStatsDRecorderTags(t *testing.T) {
+	server := listenStatsD(t)
+	recorder, err := NewStatsDRecorder(server.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.tags = true
+	defer recorder.Close()
+
+	recorder.MessageFailed("orders")
+	recorder.ShadowFailed("orders")
+	recorder.ObserveDuration("orders", 2*time.Millisecond)
+
+	expected := []string{"messages.failed:1|c|#queue:orders", "shadow.failed:1|c|#queue:orders", "processing.duration:2|h|#queue:orders"}
+	for i, line := range readStatsD(t, server, 3) {
+		if line != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], line)
+		}
+	}
+}
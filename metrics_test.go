@@ -0,0 +1,114 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// fakeMetrics is a MetricsRecorder that counts the metrics recorded for each queue.
+type fakeMetrics struct {
+	mu        sync.Mutex
+	counts    map[string]int
+	durations int
+	flushes   int
+}
+
+func (m *fakeMetrics) add(name, queue string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counts == nil {
+		m.counts = map[string]int{}
+	}
+	m.counts[name+":"+queue]++
+}
+
+func (m *fakeMetrics) MessageReceived(queue string)  { m.add("received", queue) }
+func (m *fakeMetrics) MessageSucceeded(queue string) { m.add("succeeded", queue) }
+func (m *fakeMetrics) MessageFailed(queue string)    { m.add("failed", queue) }
+func (m *fakeMetrics) MessageDeleted(queue string)   { m.add("deleted", queue) }
+func (m *fakeMetrics) ShadowSucceeded(queue string)  { m.add("shadow succeeded", queue) }
+func (m *fakeMetrics) ShadowFailed(queue string)     { m.add("shadow failed", queue) }
+
+func (m *fakeMetrics) ObserveDuration(queue string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations++
+}
+
+func (m *fakeMetrics) Flush(ctx context.Context) error {
+	m.flushes++
+	return nil
+}
+
+func TestWithMetrics(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		metrics := &fakeMetrics{}
+		opts := []Option{WithMetrics(metrics), WithLogger(nil)}
+		if batch {
+			opts = append(opts, WithBatchDelete())
+		}
+		handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+			if msg.MessageId == "bad" {
+				return errors.New("failed")
+			}
+			return nil
+		}, opts...)
+
+		handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1"), testMessage("2"), testMessage("bad")})
+
+		expected := map[string]int{
+			"received:my_queue_name":  3,
+			"succeeded:my_queue_name": 2,
+			"failed:my_queue_name":    1,
+			"deleted:my_queue_name":   2,
+		}
+		for key, count := range expected {
+			if metrics.counts[key] != count {
+				t.Errorf("expected %d for %s with batch delete %v, got %d", count, key, batch, metrics.counts[key])
+			}
+		}
+		if metrics.durations != 3 || metrics.flushes != 1 {
+			t.Errorf("expected 3 durations and a flush, got %d and %d", metrics.durations, metrics.flushes)
+		}
+	}
+}
+
+func TestQueueName(t *testing.T) {
+	if name := queueName(testQueueARN); name != "my_queue_name" {
+		t.Errorf("expected the queue name, got %q", name)
+	}
+	if name := queueName("not an arn"); name != "not an arn" {
+		t.Errorf("expected the ARN to be kept, got %q", name)
+	}
+}
+
+func TestWithMetricsPermanentFailures(t *testing.T) {
+	for _, deadLetter := range []string{testDeadLetterURL, ""} {
+		metrics := &fakeMetrics{}
+		handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+			if msg.MessageId == "bad" {
+				return Permanent(errors.New("bad payload"))
+			}
+			return nil
+		}, WithDeadLetterQueue(deadLetter), WithMetrics(metrics), WithLogger(nil))
+
+		handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1"), testMessage("bad")})
+
+		// dead-lettered and dropped messages are deleted, but didn't succeed
+		expected := map[string]int{
+			"succeeded:my_queue_name": 1,
+			"failed:my_queue_name":    1,
+			"deleted:my_queue_name":   2,
+		}
+		for key, count := range expected {
+			if metrics.counts[key] != count {
+				t.Errorf("expected %d for %s with dead-letter queue %q, got %d", count, key, deadLetter, metrics.counts[key])
+			}
+		}
+	}
+}
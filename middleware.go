@@ -0,0 +1,36 @@
+package sqsworker
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Middleware wraps a MessageProcessor to add behaviour before or after it runs, such
+// as decoding the message or skipping it altogether.
+type Middleware func(next MessageProcessor) MessageProcessor
+
+// chain wraps a processor with middleware so that the first middleware is outermost.
+func chain(processor MessageProcessor, middleware []Middleware) MessageProcessor {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		processor = middleware[i](processor)
+	}
+	return processor
+}
+
+// BatchHandlerFunc handles a whole batch of messages in the same way as
+// Handler.ProcessMessages, returning the number of messages closed.
+type BatchHandlerFunc func(ctx context.Context, messages []events.SQSMessage) (completed int, err error)
+
+// BatchMiddleware wraps the handling of a whole batch, for work that should happen
+// exactly once per invocation, such as opening a transaction or flushing a buffer.
+type BatchMiddleware func(next BatchHandlerFunc) BatchHandlerFunc
+
+// chainBatch wraps a batch handler with batch middleware so that the first middleware
+// is outermost.
+func chainBatch(handler BatchHandlerFunc, middleware []BatchMiddleware) BatchHandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
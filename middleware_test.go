@@ -0,0 +1,78 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next MessageProcessor) MessageProcessor {
+			return func(ctx context.Context, msg events.SQSMessage) error {
+				order = append(order, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		order = append(order, "processor")
+		return nil
+	}, WithMiddleware(record("first"), record("second")), WithMiddleware(record("third")))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if !reflect.DeepEqual(order, []string{"first", "second", "third", "processor"}) {
+		t.Errorf("expected middleware to run in the order given, got %v", order)
+	}
+}
+
+func TestWithBatchMiddleware(t *testing.T) {
+	var order []string
+	record := func(name string) BatchMiddleware {
+		return func(next BatchHandlerFunc) BatchHandlerFunc {
+			return func(ctx context.Context, messages []events.SQSMessage) (int, error) {
+				order = append(order, name+" before")
+				completed, err := next(ctx, messages)
+				order = append(order, name+" after")
+				return completed, err
+			}
+		}
+	}
+
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithBatchMiddleware(record("outer"), record("inner")), WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1"), testMessage("2")})
+	if completed != 2 || err != nil {
+		t.Errorf("expected both messages to complete, got %d: %v", completed, err)
+	}
+
+	expected := []string{"outer before", "inner before", "inner after", "outer after"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected the middleware to run once around the batch in order, got %v", order)
+	}
+}
+
+func TestBatchMiddlewareFailsBatch(t *testing.T) {
+	errSetup := errors.New("failed to open transaction")
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		t.Error("expected the processor not to be called")
+		return nil
+	}, WithBatchMiddleware(func(next BatchHandlerFunc) BatchHandlerFunc {
+		return func(ctx context.Context, messages []events.SQSMessage) (int, error) {
+			return 0, errSetup
+		}
+	}), WithLogger(nil))
+
+	_, err := handler.HandleWithResponse(context.Background(), events.SQSEvent{Records: []events.SQSMessage{testMessage("1")}})
+	if err != errSetup {
+		t.Errorf("expected the middleware's error to fail the whole batch, got %v", err)
+	}
+}
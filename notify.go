@@ -0,0 +1,179 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// Failure describes a message that a FailureNotifier is told about.
+type Failure struct {
+	MessageID    string    `json:"messageId"`
+	Queue        string    `json:"queue"`
+	QueueARN     string    `json:"queueArn"`
+	Error        string    `json:"error"`
+	ReceiveCount int       `json:"receiveCount"`
+	DeadLettered bool      `json:"deadLettered"`
+	Dropped      bool      `json:"dropped"`
+	FailedAt     time.Time `json:"failedAt"`
+}
+
+// FailureNotifier is told about messages that need someone's attention: each message
+// as it's dead-lettered, or dropped for failing permanently without a dead-letter queue,
+// and the messages that failed once each batch is done.  Messages
+// that were cancelled, skipped or left for the deadline rather than failing themselves
+// aren't included.  Errors from the notifier are only logged.
+type FailureNotifier interface {
+	NotifyFailures(ctx context.Context, failures []Failure) error
+}
+
+// newFailure describes a failed message for a FailureNotifier.
+func (s *Handler) newFailure(msg events.SQSMessage, err error, deadLettered bool) Failure {
+	return Failure{
+		MessageID:    msg.MessageId,
+		Queue:        s.queueLabel(msg.EventSourceARN),
+		QueueARN:     msg.EventSourceARN,
+		Error:        err.Error(),
+		ReceiveCount: receiveCount(msg),
+		DeadLettered: deadLettered,
+		FailedAt:     time.Now().UTC(),
+	}
+}
+
+// notifyPermanentFailure tells the notifier about a message that failed permanently
+// and has been dead-lettered, or dropped if there's no dead-letter queue.
+func (s *Handler) notifyPermanentFailure(ctx context.Context, msg events.SQSMessage, cause error) {
+	if s.notifier == nil {
+		return
+	}
+
+	failure := s.newFailure(msg, cause, s.deadLetter != "")
+	failure.Dropped = s.deadLetter == ""
+	if err := s.notifier.NotifyFailures(ctx, []Failure{failure}); err != nil {
+		s.logMessage(ctx, "failed to send notification for permanently failed message %s: %v", msg.MessageId, err)
+	}
+}
+
+// notifyFailures tells the notifier about the messages in a batch that failed.
+func (s *Handler) notifyFailures(ctx context.Context, outcomes []outcome) {
+	if s.notifier == nil {
+		return
+	}
+
+	var failures []Failure
+	for _, res := range outcomes {
+		if failsBatch(res) {
+			failures = append(failures, s.newFailure(res.msg, res.err, false))
+		}
+	}
+	if len(failures) == 0 {
+		return
+	}
+
+	if err := s.notifier.NotifyFailures(context.WithoutCancel(ctx), failures); err != nil {
+		s.logger.Printf("failed to send notification for %d failed message(s): %v", len(failures), err)
+	}
+}
+
+// failureNotification is the body sent by the included notifiers.
+type failureNotification struct {
+	Failures []Failure `json:"failures"`
+}
+
+// SNSNotifier is a FailureNotifier that publishes the failures to an SNS topic as a
+// JSON object with a failures array, for alerting by email, chat or paging
+// subscriptions.
+type SNSNotifier struct {
+	Client   PartialSNSClient
+	TopicARN string
+}
+
+// NewSNSNotifier creates an SNSNotifier that publishes to the given topic.
+func NewSNSNotifier(client PartialSNSClient, topicARN string) *SNSNotifier {
+	return &SNSNotifier{Client: client, TopicARN: topicARN}
+}
+
+// NotifyFailures implements FailureNotifier.
+func (n *SNSNotifier) NotifyFailures(ctx context.Context, failures []Failure) error {
+	body, err := json.Marshal(failureNotification{Failures: failures})
+	if err != nil {
+		return err
+	}
+
+	_, err = n.Client.PublishWithContext(ctx, &sns.PublishInput{
+		TopicArn: aws.String(n.TopicARN),
+		Subject:  aws.String(failureSubject(failures)),
+		Message:  aws.String(string(body)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish failures to %s: %w", n.TopicARN, err)
+	}
+	return nil
+}
+
+// failureSubject sums up the failures for the subject of an email notification.
+func failureSubject(failures []Failure) string {
+	if len(failures) == 1 && failures[0].DeadLettered {
+		return fmt.Sprintf("Message dead-lettered from %s", failures[0].Queue)
+	}
+	if len(failures) == 1 && failures[0].Dropped {
+		return fmt.Sprintf("Message dropped from %s", failures[0].Queue)
+	}
+	return fmt.Sprintf("%d message(s) failed on %s", len(failures), failures[0].Queue)
+}
+
+// WebhookNotifier is a FailureNotifier that POSTs the failures to a URL as a JSON
+// object with a failures array.
+type WebhookNotifier struct {
+	// URL is where the failures are sent.
+	URL string
+	// Header holds any further headers to send, such as Authorization.
+	Header http.Header
+	// Client is the HTTP client used to send, and defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a WebhookNotifier that sends to the given URL.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url}
+}
+
+// NotifyFailures implements FailureNotifier.  Any response other than a 2xx is an
+// error.
+func (n *WebhookNotifier) NotifyFailures(ctx context.Context, failures []Failure) error {
+	body, err := json.Marshal(failureNotification{Failures: failures})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range n.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send failures to webhook: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("failed to send failures to webhook: unexpected status %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// fakeNotifier is a FailureNotifier that records each notification.
+type fakeNotifier struct {
+	mu            sync.Mutex
+	notifications [][]Failure
+}
+
+func (n *fakeNotifier) NotifyFailures(ctx context.Context, failures []Failure) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, failures)
+	return nil
+}
+
+func TestWithFailureNotifier(t *testing.T) {
+	notifier := &fakeNotifier{}
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		switch msg.MessageId {
+		case "bad":
+			return Permanent(errors.New("bad payload"))
+		case "flaky":
+			return errors.New("unavailable")
+		}
+		return nil
+	}, WithDeadLetterQueue(testDeadLetterURL), WithFailureNotifier(notifier), WithLogger(nil))
+
+	flaky := testMessage("flaky")
+	flaky.Attributes = map[string]string{"ApproximateReceiveCount": "3"}
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("bad"), flaky, testMessage("ok")})
+
+	if len(notifier.notifications) != 2 {
+		t.Fatalf("expected a notification for the dead-letter and one for the batch, got %v", notifier.notifications)
+	}
+
+	deadLettered := notifier.notifications[0]
+	if len(deadLettered) != 1 || deadLettered[0].MessageID != "bad" || !deadLettered[0].DeadLettered ||
+		deadLettered[0].Error != "bad payload" {
+		t.Errorf("expected the dead-lettered message first, got %+v", deadLettered)
+	}
+
+	failed := notifier.notifications[1]
+	if len(failed) != 1 || failed[0].MessageID != "flaky" || failed[0].DeadLettered ||
+		failed[0].Queue != "my_queue_name" || failed[0].ReceiveCount != 3 {
+		t.Errorf("expected the failed message in the batch notification, got %+v", failed)
+	}
+}
+
+func TestWithFailureNotifierNoFailures(t *testing.T) {
+	notifier := &fakeNotifier{}
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "0" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithSequential(), WithFailureNotifier(notifier), WithLogger(nil))
+
+	handler.ProcessMessages(context.Background(), testMessages(3))
+	handler.ProcessMessages(context.Background(), testMessages(3)[1:])
+
+	// the messages skipped after the failure shouldn't be included, and a batch without
+	// failures shouldn't be notified at all
+	if len(notifier.notifications) != 1 || len(notifier.notifications[0]) != 1 {
+		t.Errorf("expected a single notification for the failed message, got %v", notifier.notifications)
+	}
+}
+
+func TestSNSNotifier(t *testing.T) {
+	client := &fakeSNSClient{}
+	notifier := NewSNSNotifier(client, "arn:aws:sns:us-west-2:123456:alerts")
+
+	err := notifier.NotifyFailures(context.Background(), []Failure{{MessageID: "1", Queue: "orders", DeadLettered: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.published) != 1 {
+		t.Fatalf("expected a single message to be published, got %d", len(client.published))
+	}
+	input := client.published[0]
+	if aws.StringValue(input.Subject) != "Message dead-lettered from orders" {
+		t.Errorf("unexpected subject %q", aws.StringValue(input.Subject))
+	}
+
+	var body failureNotification
+	if err := json.Unmarshal([]byte(aws.StringValue(input.Message)), &body); err != nil || len(body.Failures) != 1 {
+		t.Errorf("expected the failures as JSON, got %s: %v", aws.StringValue(input.Message), err)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var body failureNotification
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL)
+	notifier.Header = http.Header{"Authorization": {"Bearer token"}}
+
+	err := notifier.NotifyFailures(context.Background(), []Failure{{MessageID: "1"}, {MessageID: "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Failures) != 2 || body.Failures[1].MessageID != "2" {
+		t.Errorf("expected the failures to be sent, got %+v", body)
+	}
+	if auth != "Bearer token" {
+		t.Errorf("expected the extra headers to be sent, got %q", auth)
+	}
+}
+
+func TestWebhookNotifierStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL).NotifyFailures(context.Background(), []Failure{{MessageID: "1"}})
+	if err == nil {
+		t.Error("expected an error for an unsuccessful response")
+	}
+}
+
+func TestWithFailureNotifierDropped(t *testing.T) {
+	notifier := &fakeNotifier{}
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return Permanent(errors.New("bad payload"))
+	}, WithFailureNotifier(notifier), WithLogger(nil))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("bad")})
+
+	if len(notifier.notifications) != 1 {
+		t.Fatalf("expected a notification for the dropped message, got %v", notifier.notifications)
+	}
+	dropped := notifier.notifications[0]
+	if len(dropped) != 1 || dropped[0].MessageID != "bad" || !dropped[0].Dropped || dropped[0].DeadLettered {
+		t.Errorf("expected the message to be notified as dropped, got %+v", dropped)
+	}
+	if subject := failureSubject(dropped); subject != "Message dropped from my_queue_name" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+}
@@ -0,0 +1,394 @@
+package sqsworker
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Option configures optional behaviour of a Handler.
+type Option func(*Handler)
+
+// WithRetry retries messages that fail processing within the same invocation,
+// using the given policy to decide how many times and how long to wait between
+// attempts.  Messages that still fail once the policy gives up are left on the
+// queue for redelivery as normal.
+func WithRetry(policy RetryPolicy) Option {
+	return func(s *Handler) {
+		s.retry = policy
+	}
+}
+
+// WithErrorClassifier sets the classifier used to decide whether a failed message
+// is retried, left for redelivery, or removed from the queue.  Errors wrapped with
+// Permanent or Transient keep their class regardless of the classifier.
+func WithErrorClassifier(classifier ErrorClassifier) Option {
+	return func(s *Handler) {
+		s.classify = classifier
+	}
+}
+
+// WithDeadLetterQueue forwards messages that fail permanently to the queue with the
+// given URL, along with details of the failure, before deleting them from the source
+// queue.  The handler's client must implement PartialSQSSender.
+func WithDeadLetterQueue(queueURL string) Option {
+	return func(s *Handler) {
+		s.deadLetter = queueURL
+	}
+}
+
+// WithMaxReceiveCount treats messages that have been received more than the given
+// number of times as poison.  They skip the processor entirely and fail permanently
+// with ErrPoisonMessage, so they're dead-lettered or dropped straight away.
+func WithMaxReceiveCount(count int) Option {
+	return func(s *Handler) {
+		s.maxReceive = count
+	}
+}
+
+// WithHeartbeat extends the visibility timeout of each message to the given extension
+// every interval for as long as it's being processed, so that slow processors don't
+// see their messages delivered to another invocation part way through.  The interval
+// should be comfortably shorter than both the extension and the queue's visibility
+// timeout.
+func WithHeartbeat(interval, extension time.Duration) Option {
+	return func(s *Handler) {
+		s.heartbeat = heartbeat{interval: interval, extension: extension}
+	}
+}
+
+// WithBatchDelete deletes completed messages with a single DeleteMessageBatch call per
+// queue once the whole batch has been processed, instead of deleting each message as
+// soon as it completes.  Entries that fail to delete are retried, backing off with the
+// handler's retry policy, before being reported as failures.  Clients that don't
+// implement PartialSQSBatchDeleter delete the messages one at a time instead.
+func WithBatchDelete() Option {
+	return func(s *Handler) {
+		s.batchDelete = true
+	}
+}
+
+// WithQueueURLResolver sets how the handler finds the URL of the queue each message
+// came from.  By default the URL is built from the queue's ARN without calling AWS.
+func WithQueueURLResolver(resolver QueueURLResolver) Option {
+	return func(s *Handler) {
+		s.resolver = resolver
+	}
+}
+
+// WithEndpoint builds queue URLs using the given endpoint instead of the standard
+// AWS domain, for VPC endpoints or local stand-ins such as LocalStack.  It only changes
+// the default resolver, so has no effect alongside WithQueueURLResolver, whichever
+// order they're given in.
+func WithEndpoint(endpoint string) Option {
+	return func(s *Handler) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithFIFO preserves the ordering guarantees of FIFO queues.  Messages are grouped by
+// their MessageGroupId and each group is processed one message at a time in sequence
+// number order, while separate groups are still processed in parallel.  Once a message
+// fails, the rest of its group is skipped with ErrSkipped and left on the queue, so
+// that later messages can't take effect before it.
+func WithFIFO() Option {
+	return func(s *Handler) {
+		s.fifo = true
+	}
+}
+
+// WithDeduplication processes messages in the same batch that share a key only once,
+// such as DedupByMessageID for duplicate deliveries from a standard queue.  The
+// duplicates share the outcome of the message that was processed, so they're deleted
+// along with it on success and left on the queue with it on failure.
+func WithDeduplication(key DedupKey) Option {
+	return func(s *Handler) {
+		s.dedup = key
+	}
+}
+
+// WithIdempotencyStore consults the store before processing each message and skips,
+// and deletes, any message it has seen before.  Messages are marked as processed in the
+// store for the given ttl once they complete.  The key defaults to the message ID when
+// nil.
+func WithIdempotencyStore(store IdempotencyStore, ttl time.Duration, key DedupKey) Option {
+	return func(s *Handler) {
+		if key == nil {
+			key = DedupByMessageID
+		}
+		s.idempotency = idempotency{store: store, ttl: ttl, key: key}
+	}
+}
+
+// WithMiddleware wraps the processor with the given middleware.  The first middleware
+// given is the outermost, so it sees each message first.  The option can be given more
+// than once to add more middleware.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(s *Handler) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+// WithBatchMiddleware wraps the handling of each whole batch with the given middleware,
+// with the first middleware given outermost.  It can return an error of its own to fail
+// the whole batch, even with HandleWithResponse.  The option can be given more than once
+// to add more middleware.
+func WithBatchMiddleware(middleware ...BatchMiddleware) Option {
+	return func(s *Handler) {
+		s.batchMiddleware = append(s.batchMiddleware, middleware...)
+	}
+}
+
+// WithRateLimit limits how often the processor is called, retries included, to rps
+// calls per second with bursts of up to burst calls.  The limit is shared by every
+// message in a batch, and by every batch the handler processes, so that processors
+// calling rate limited APIs stay within their quota.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(s *Handler) {
+		if burst < 1 {
+			burst = 1
+		}
+		s.limiter = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+}
+
+// WithCircuitBreaker stops calling the processor once it has failed with a transient
+// error threshold times in a row, leaving the remaining messages on the queue with
+// ErrCircuitOpen rather than pounding a dependency that's down.  The processor isn't
+// called again until the cooldown has passed, even in later warm invocations, and a
+// single failure after that trips the breaker again.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(s *Handler) {
+		if threshold < 1 {
+			threshold = 1
+		}
+		s.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown}
+	}
+}
+
+// WithDeadlineMargin stops processing messages once less than the margin remains before
+// the invocation's deadline.  Messages that haven't started are left on the queue with
+// ErrDeadline, and the context given to in-flight processors is cancelled so they can
+// stop gracefully, leaving enough time to delete the messages that did complete before
+// Lambda stops the function.  Messages that did complete are still published, forwarded
+// and marked as processed afterwards.  Processors should return promptly once their
+// context is done.
+func WithDeadlineMargin(margin time.Duration) Option {
+	return func(s *Handler) {
+		s.margin = margin
+	}
+}
+
+// WithChunkSize processes batches larger than n messages in chunks of n, one chunk
+// after another, for event source mappings with batching windows and batch sizes of up
+// to 10,000.  Each chunk's completed messages are deleted before the next chunk starts,
+// and once the deadline margin is reached the rest of the batch is left on the queue
+// with ErrDeadline.  Duplicates are only held back within a chunk.
+func WithChunkSize(n int) Option {
+	return func(s *Handler) {
+		s.chunkSize = n
+	}
+}
+
+// WithMessageTimeout limits how long each message can take to process, retries
+// included, so that one hung message can't use up the whole invocation.  When the
+// timeout passes, the processor's context is cancelled and the message fails
+// straight away as a transient failure wrapping ErrMessageTimeout.  A processor that
+// ignores its context is left running in the background.
+func WithMessageTimeout(d time.Duration) Option {
+	return func(s *Handler) {
+		s.messageTimeout = d
+	}
+}
+
+// WithMaxConcurrency limits how many messages are processed at the same time, or how
+// many message groups with WithFIFO.  By default every message in the batch is
+// processed at once.  The limit is shared by every batch the handler processes at once,
+// such as with a Poller.
+func WithMaxConcurrency(n int) Option {
+	return func(s *Handler) {
+		s.concurrency = n
+	}
+}
+
+// WithLogger sets the Logger the handler reports to, in place of stdout.  A nil
+// Logger discards everything.
+func WithLogger(logger Logger) Option {
+	return func(s *Handler) {
+		if logger == nil {
+			logger = discardLogger{}
+		}
+		s.logger = logger
+	}
+}
+
+// WithHooks adds hooks that are called as each message and batch is handled.  Hooks given by
+// separate options are all called, in the order they were given.
+func WithHooks(hooks Hooks) Option {
+	return func(s *Handler) {
+		s.hooks = append(s.hooks, hooks)
+	}
+}
+
+// WithCapture writes every batch the handler receives to a JSON file in the given
+// directory before processing it, so that it can be replayed later with Replay or the
+// sqsreplay command.  In Lambda the directory must be under /tmp.
+func WithCapture(dir string) Option {
+	return func(s *Handler) {
+		s.captureDir = dir
+	}
+}
+
+// WithTaskTokens completes the Step Functions tasks waiting on messages that carry a
+// task token, for the SQS callback pattern.  Messages that complete send a task success
+// with any output given with SetOutput, or "{}" if there isn't any, and messages that
+// fail permanently send a task failure.  A nil source reads tokens from the TaskToken
+// message attribute.
+func WithTaskTokens(client PartialSFNClient, source TaskTokenSource) Option {
+	return func(s *Handler) {
+		if source == nil {
+			source = TaskTokenFromAttribute(TaskTokenAttribute)
+		}
+		s.tasks = taskCallback{client: client, token: source}
+	}
+}
+
+// WithPublishTopic publishes the output of each message that completes to the SNS topic
+// with the given ARN, for chaining workers together.  The output is what the processor
+// gave with SetOutput, and messages without any output publish nothing.  If publishing
+// fails, the message is left on the queue to be processed again.
+func WithPublishTopic(client PartialSNSClient, topicARN string) Option {
+	return func(s *Handler) {
+		s.publish = publisher{client: client, topicARN: topicARN}
+	}
+}
+
+// WithForwardQueue sends each message that completes on to the queue with the given
+// URL, for pipelines of workers.  The message is sent with the output the processor
+// gave with SetOutput as its body, or its original body if there isn't any, and keeps
+// its message attributes.  Messages forwarded to a FIFO queue keep their group and
+// deduplication ID.  If forwarding fails, the message is left on the queue to be
+// processed again.  The handler's client must implement PartialSQSSender.
+func WithForwardQueue(queueURL string) Option {
+	return func(s *Handler) {
+		s.forward = queueURL
+	}
+}
+
+// WithArchive writes messages to S3 as JSON, along with their metadata and outcome,
+// for auditing and replay.  The config's mode decides whether every message is archived
+// as it's received or once it's been handled, or only the ones that fail.  Uploads
+// happen in the background while the batch is processed and are waited for before the
+// batch returns, and failed uploads are only logged.
+func WithArchive(client PartialS3Uploader, config ArchiveConfig) Option {
+	return func(s *Handler) {
+		s.archive = newArchiver(client, config)
+	}
+}
+
+// WithCorrelationID reads a correlation ID from each message with the given source and
+// makes it available to processors through CorrelationID, adding it to the handler's
+// log lines about the message.  Messages without one use their message ID.  The ID is
+// propagated on messages sent on with WithForwardQueue and WithPublishTopic as the
+// message attribute with the given name, or CorrelationId if it's empty.  A nil source
+// reads the ID from that same attribute.
+func WithCorrelationID(attribute string, source CorrelationIDSource) Option {
+	return func(s *Handler) {
+		if attribute == "" {
+			attribute = CorrelationIDAttribute
+		}
+		if source == nil {
+			source = CorrelationIDFromAttribute(attribute)
+		}
+		s.correlation = correlation{attribute: attribute, source: source}
+	}
+}
+
+// WithDryRun stops the handler changing anything, for trying a new worker out against
+// a live queue.  Messages are processed as normal, but instead of deleting them, changing
+// their visibility, or sending them to another queue, topic or task, the handler logs
+// what it would have done.  Messages aren't marked as processed in the idempotency
+// store, archived to S3 or captured either.  Side effects of the processor and middleware themselves still happen.
+func WithDryRun(enabled bool) Option {
+	return func(s *Handler) {
+		s.dryRun.enabled = enabled
+	}
+}
+
+// WithDryRunSkipProcessors enables a dry run as WithDryRun does, but without calling
+// the processor or middleware either, treating every message as if it completed.
+func WithDryRunSkipProcessors() Option {
+	return func(s *Handler) {
+		s.dryRun = dryRun{enabled: true, skipProcessors: true}
+	}
+}
+
+// WithDeletePolicy sets which messages the handler deletes from the queue itself.
+func WithDeletePolicy(policy DeletePolicy) Option {
+	return func(s *Handler) {
+		s.deletePolicy = policy
+	}
+}
+
+// WithFailFast cancels the rest of the batch as soon as any message fails, for batches
+// where partial processing is worse than processing the whole batch again.  Messages
+// that haven't started aren't processed, and the context given to in-flight processors
+// is cancelled.  Unless they complete anyway, these messages are left on the queue and
+// reported with ErrCancelled rather than as failures of their own.
+func WithFailFast() Option {
+	return func(s *Handler) {
+		s.failFast = true
+	}
+}
+
+// WithSequential processes messages strictly in the order they appear in the event, one
+// at a time, for processors with side effects that depend on order even on standard
+// queues.  When a message fails, the rest of the batch is skipped and left on the queue
+// with ErrSkipped so that nothing is processed out of order.  It takes precedence over
+// WithFIFO and WithMaxConcurrency.
+func WithSequential() Option {
+	return func(s *Handler) {
+		s.sequential = true
+	}
+}
+
+// WithMetrics sends metrics about each message to the given recorder, such as a
+// StatsDRecorder, DatadogRecorder or PrometheusRecorder.  A nil recorder turns metrics
+// off again.
+func WithMetrics(recorder MetricsRecorder) Option {
+	return func(s *Handler) {
+		if recorder == nil {
+			recorder = noopMetrics{}
+		}
+		s.metrics = recorder
+	}
+}
+
+// WithBatchResponse marks the handler as being for an event source mapping with
+// ReportBatchItemFailures enabled, so that Start and LambdaHandler use
+// HandleWithResponse.
+func WithBatchResponse() Option {
+	return func(s *Handler) {
+		s.batchResponse = true
+	}
+}
+
+// WithShadow gives a sample of messages, such as 0.05 for 5%, to a second processor as
+// well as the main one, for trying out a new processor against live traffic.  The shadow
+// runs alongside the main processor, without its middleware, and its failures are only
+// logged and counted by recorders that implement ShadowRecorder; they never change
+// whether a message is deleted or retried.
+func WithShadow(processor MessageProcessor, rate float64) Option {
+	return func(s *Handler) {
+		s.shadow = newShadow(processor, rate)
+	}
+}
+
+// WithFailureNotifier tells the given notifier, such as an SNSNotifier or
+// WebhookNotifier, about each message that's dead-lettered and the messages that
+// failed in each batch.
+func WithFailureNotifier(notifier FailureNotifier) Option {
+	return func(s *Handler) {
+		s.notifier = notifier
+	}
+}
@@ -0,0 +1,60 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// output holds the value a processor gave with SetOutput.
+type output struct {
+	mu    sync.Mutex
+	value interface{}
+	set   bool
+}
+
+// SetOutput records the output of processing the current message, for the handler to
+// pass on once the message completes, such as to a Step Functions task.  Values are
+// sent as JSON, except for json.RawMessage and []byte values which are sent as they are.
+// It returns false if the context didn't come from a Handler.
+func SetOutput(ctx context.Context, value interface{}) bool {
+	out, ok := ctx.Value(outputKey).(*output)
+	if !ok {
+		return false
+	}
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.value, out.set = value, true
+
+	return true
+}
+
+// withOutput gives the context somewhere for SetOutput to record the output.
+func withOutput(ctx context.Context) context.Context {
+	return context.WithValue(ctx, outputKey, &output{})
+}
+
+// outputJSON returns the output recorded with SetOutput as JSON, if there is any.
+func outputJSON(ctx context.Context) ([]byte, bool, error) {
+	out, ok := ctx.Value(outputKey).(*output)
+	if !ok {
+		return nil, false, nil
+	}
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if !out.set {
+		return nil, false, nil
+	}
+
+	switch v := out.value.(type) {
+	case json.RawMessage:
+		return v, true, nil
+	case []byte:
+		return v, true, nil
+	}
+
+	data, err := json.Marshal(out.value)
+	return data, true, err
+}
@@ -0,0 +1,39 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestSetOutput(t *testing.T) {
+	if SetOutput(context.Background(), "ignored") {
+		t.Error("expected SetOutput to fail without a handler's context")
+	}
+
+	cases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{json.RawMessage(`{"raw":true}`), `{"raw":true}`},
+		{[]byte("bytes"), "bytes"},
+		{"text", `"text"`},
+		{struct{ ID int }{1}, `{"ID":1}`},
+	}
+
+	for _, c := range cases {
+		ctx := withOutput(context.Background())
+		if !SetOutput(ctx, c.value) {
+			t.Fatal("expected SetOutput to succeed")
+		}
+
+		data, ok, err := outputJSON(ctx)
+		if err != nil || !ok || string(data) != c.expected {
+			t.Errorf("expected output %s, got %s %v %v", c.expected, data, ok, err)
+		}
+	}
+
+	if _, ok, _ := outputJSON(withOutput(context.Background())); ok {
+		t.Error("expected no output when none was set")
+	}
+}
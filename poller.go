@@ -0,0 +1,239 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const (
+	// maxReceiveBatchSize is the most messages ReceiveMessage will return at once.
+	maxReceiveBatchSize = 10
+	// maxReceiveWaitTime is the longest ReceiveMessage will long poll for.
+	maxReceiveWaitTime = 20 * time.Second
+	// defaultReceiveErrorDelay is how long the poller waits after a failed receive.
+	defaultReceiveErrorDelay = time.Second
+)
+
+// PartialSQSReceiver is a partial SQS client that can receive messages from a queue.
+type PartialSQSReceiver interface {
+	ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error)
+	GetQueueAttributesWithContext(ctx aws.Context, input *sqs.GetQueueAttributesInput, opts ...request.Option) (*sqs.GetQueueAttributesOutput, error)
+}
+
+// Poller receives messages from a queue itself and passes each batch to a Handler, for
+// running the same processing code outside of Lambda, such as locally, in a container,
+// or to drain a queue.
+type Poller struct {
+	Client   PartialSQSReceiver
+	QueueURL string
+	Handler  *Handler
+	// QueueARN is the ARN given to received messages as their event source.  It's
+	// looked up with GetQueueAttributes when the poller starts if it's left empty.
+	QueueARN string
+	// WaitTime is how long each receive long polls for messages, up to 20 seconds.
+	// Zero uses the full 20 seconds, and a negative wait time short polls, returning
+	// straight away whether or not there are any messages.
+	WaitTime time.Duration
+	// BatchSize is the most messages received at once, up to 10, and 10 when it's zero.
+	BatchSize int
+	// VisibilityTimeout overrides the queue's visibility timeout for received messages
+	// when it's set.
+	VisibilityTimeout time.Duration
+	// ErrorDelay is how long to wait before receiving again after a receive fails, and
+	// a second when it's zero.
+	ErrorDelay time.Duration
+	// ShutdownTimeout limits how long the batch being processed when the poller is
+	// stopped has to finish before its context is cancelled.  Zero waits for as long
+	// as the batch takes.
+	ShutdownTimeout time.Duration
+}
+
+// NewPoller creates a Poller that receives messages from the queue with the given URL
+// in batches of 10, long polling for 20 seconds at a time.
+func NewPoller(client PartialSQSReceiver, queueURL string, handler *Handler) *Poller {
+	return &Poller{
+		Client:     client,
+		QueueURL:   queueURL,
+		Handler:    handler,
+		WaitTime:   maxReceiveWaitTime,
+		BatchSize:  maxReceiveBatchSize,
+		ErrorDelay: defaultReceiveErrorDelay,
+	}
+}
+
+// Run receives and processes batches of messages until the context is done.  The
+// batch being processed at the time is allowed to finish before Run returns, with
+// ShutdownTimeout to finish in if it's set.  The only errors returned are for failing
+// to look up the queue's ARN; failed receives are logged and tried again.
+func (p *Poller) Run(ctx context.Context) error {
+	if p.QueueARN == "" {
+		arn, err := p.lookupQueueARN(ctx)
+		if err != nil {
+			return err
+		}
+		p.QueueARN = arn
+	}
+
+	for ctx.Err() == nil {
+		out, err := p.Client.ReceiveMessageWithContext(ctx, p.receiveInput())
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			p.Handler.logger.Printf("failed to receive messages from %s: %v", p.QueueURL, err)
+			sleep(ctx, p.errorDelay())
+			continue
+		}
+
+		if len(out.Messages) == 0 {
+			continue
+		}
+
+		p.process(ctx, out.Messages)
+	}
+
+	return nil
+}
+
+// RunUntilSignal runs the poller until the context is done or the process receives
+// SIGTERM or SIGINT, shutting down gracefully as Run does.
+func (p *Poller) RunUntilSignal(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return p.Run(ctx)
+}
+
+// process passes a batch of received messages to the handler.  Failed messages are
+// left on the queue to be received again, so their errors are only logged by the
+// handler.
+func (p *Poller) process(ctx context.Context, messages []*sqs.Message) {
+	ctx, cancel := p.processContext(ctx)
+	defer cancel()
+
+	records := make([]events.SQSMessage, len(messages))
+	for i, msg := range messages {
+		records[i] = toSQSMessage(msg, p.QueueARN)
+	}
+
+	p.Handler.Handle(ctx, events.SQSEvent{Records: records})
+}
+
+// processContext derives the context a batch is processed with, which isn't cancelled
+// when the poller is stopped until the shutdown timeout has passed.
+func (p *Poller) processContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	procCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if p.ShutdownTimeout <= 0 {
+		return procCtx, cancel
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(p.ShutdownTimeout, cancel)
+	})
+
+	return procCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// receiveInput builds the input for each receive.  Fields left as zero, as they are in
+// a Poller created without NewPoller, get NewPoller's defaults, so that the poller only
+// short polls when it's asked to.
+func (p *Poller) receiveInput() *sqs.ReceiveMessageInput {
+	waitTime, batchSize := p.WaitTime, p.BatchSize
+	switch {
+	case waitTime == 0:
+		waitTime = maxReceiveWaitTime
+	case waitTime < 0:
+		waitTime = 0
+	}
+	if batchSize == 0 {
+		batchSize = maxReceiveBatchSize
+	}
+
+	input := &sqs.ReceiveMessageInput{
+		QueueUrl:              &p.QueueURL,
+		MaxNumberOfMessages:   aws.Int64(int64(clamp(batchSize, 1, maxReceiveBatchSize))),
+		WaitTimeSeconds:       aws.Int64(int64(clamp(int(waitTime/time.Second), 0, int(maxReceiveWaitTime/time.Second)))),
+		AttributeNames:        aws.StringSlice([]string{sqs.QueueAttributeNameAll}),
+		MessageAttributeNames: aws.StringSlice([]string{"All"}),
+	}
+
+	if p.VisibilityTimeout > 0 {
+		input.VisibilityTimeout = aws.Int64(visibilityTimeout(p.VisibilityTimeout))
+	}
+
+	return input
+}
+
+// errorDelay is how long to wait after a failed receive.
+func (p *Poller) errorDelay() time.Duration {
+	if p.ErrorDelay == 0 {
+		return defaultReceiveErrorDelay
+	}
+	return p.ErrorDelay
+}
+
+func (p *Poller) lookupQueueARN(ctx context.Context) (string, error) {
+	out, err := p.Client.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       &p.QueueURL,
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameQueueArn}),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the ARN of queue %s: %w", p.QueueURL, err)
+	}
+
+	return aws.StringValue(out.Attributes[sqs.QueueAttributeNameQueueArn]), nil
+}
+
+// toSQSMessage converts a message received from a queue with the given ARN into the
+// form Lambda delivers it in.
+func toSQSMessage(msg *sqs.Message, queueARN string) events.SQSMessage {
+	record := events.SQSMessage{
+		MessageId:              aws.StringValue(msg.MessageId),
+		ReceiptHandle:          aws.StringValue(msg.ReceiptHandle),
+		Body:                   aws.StringValue(msg.Body),
+		Md5OfBody:              aws.StringValue(msg.MD5OfBody),
+		Md5OfMessageAttributes: aws.StringValue(msg.MD5OfMessageAttributes),
+		Attributes:             aws.StringValueMap(msg.Attributes),
+		MessageAttributes:      make(map[string]events.SQSMessageAttribute, len(msg.MessageAttributes)),
+		EventSource:            "aws:sqs",
+		EventSourceARN:         queueARN,
+	}
+
+	if parsed, err := parseQueueARN(queueARN); err == nil {
+		record.AWSRegion = parsed.region
+	}
+
+	for name, attr := range msg.MessageAttributes {
+		record.MessageAttributes[name] = events.SQSMessageAttribute{
+			DataType:         aws.StringValue(attr.DataType),
+			StringValue:      attr.StringValue,
+			BinaryValue:      attr.BinaryValue,
+			StringListValues: aws.StringValueSlice(attr.StringListValues),
+			BinaryListValues: attr.BinaryListValues,
+		}
+	}
+
+	return record
+}
+
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
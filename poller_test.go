@@ -0,0 +1,174 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// fakeSQSReceiver is a PartialSQSReceiver that returns the given batches in turn, and
+// then long polls until the context is done.
+type fakeSQSReceiver struct {
+	mu       sync.Mutex
+	batches  [][]*sqs.Message
+	errs     []error
+	inputs   []*sqs.ReceiveMessageInput
+	lookedUp bool
+}
+
+func (r *fakeSQSReceiver) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	r.mu.Lock()
+	r.inputs = append(r.inputs, input)
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		r.mu.Unlock()
+		return nil, err
+	}
+	if len(r.batches) > 0 {
+		batch := r.batches[0]
+		r.batches = r.batches[1:]
+		r.mu.Unlock()
+		return &sqs.ReceiveMessageOutput{Messages: batch}, nil
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (r *fakeSQSReceiver) GetQueueAttributesWithContext(ctx aws.Context, input *sqs.GetQueueAttributesInput, opts ...request.Option) (*sqs.GetQueueAttributesOutput, error) {
+	r.lookedUp = true
+	return &sqs.GetQueueAttributesOutput{
+		Attributes: map[string]*string{sqs.QueueAttributeNameQueueArn: aws.String(testQueueARN)},
+	}, nil
+}
+
+func receivedMessage(id string) *sqs.Message {
+	return &sqs.Message{
+		MessageId:     aws.String(id),
+		ReceiptHandle: aws.String("handle-" + id),
+		Body:          aws.String("body " + id),
+		Attributes:    map[string]*string{"ApproximateReceiveCount": aws.String("2")},
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"type": {DataType: aws.String("String"), StringValue: aws.String("test")},
+		},
+	}
+}
+
+func TestPoller(t *testing.T) {
+	client := &fakeSQSClient{}
+	receiver := &fakeSQSReceiver{
+		batches: [][]*sqs.Message{{receivedMessage("1"), receivedMessage("2")}, {receivedMessage("3")}},
+		errs:    []error{errors.New("throttled")},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	processed := 0
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.EventSourceARN != testQueueARN || ReceiveCount(ctx) != 2 || *msg.MessageAttributes["type"].StringValue != "test" {
+			t.Errorf("unexpected message %+v", msg)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if processed++; processed == 3 {
+			cancel()
+		}
+		return nil
+	})
+
+	poller := NewPoller(receiver, "https://sqs.us-west-2.amazonaws.com/123456/my_queue_name", handler)
+	poller.ErrorDelay = time.Millisecond
+	poller.WaitTime = time.Minute
+
+	if err := poller.Run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !receiver.lookedUp {
+		t.Error("expected the queue ARN to be looked up")
+	}
+	if len(client.deleted) != 3 {
+		t.Errorf("expected all 3 messages to be deleted, got %v", client.deleted)
+	}
+	if input := receiver.inputs[0]; *input.WaitTimeSeconds != 20 || *input.MaxNumberOfMessages != 10 {
+		t.Errorf("unexpected receive input %+v", input)
+	}
+}
+
+func TestPollerDefaults(t *testing.T) {
+	poller := &Poller{QueueURL: "https://sqs.us-west-2.amazonaws.com/123456/my_queue_name"}
+
+	if input := poller.receiveInput(); *input.WaitTimeSeconds != 20 || *input.MaxNumberOfMessages != 10 {
+		t.Errorf("expected a poller without a wait time to long poll, got %+v", input)
+	}
+	if delay := poller.errorDelay(); delay != defaultReceiveErrorDelay {
+		t.Errorf("expected the default error delay, got %v", delay)
+	}
+}
+
+func TestPollerShortPolling(t *testing.T) {
+	poller := &Poller{QueueURL: "https://sqs.us-west-2.amazonaws.com/123456/my_queue_name", WaitTime: -1}
+
+	if input := poller.receiveInput(); *input.WaitTimeSeconds != 0 {
+		t.Errorf("expected a negative wait time to short poll, got %d", *input.WaitTimeSeconds)
+	}
+}
+
+func TestPollerShutdown(t *testing.T) {
+	client := &fakeSQSClient{}
+	receiver := &fakeSQSReceiver{batches: [][]*sqs.Message{{receivedMessage("1")}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		// stop the poller part way through processing the batch
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		return ctx.Err()
+	})
+
+	poller := NewPoller(receiver, "https://sqs.us-west-2.amazonaws.com/123456/my_queue_name", handler)
+	poller.QueueARN = testQueueARN
+	poller.Run(ctx)
+
+	if receiver.lookedUp {
+		t.Error("expected the given queue ARN to be used")
+	}
+	if len(client.deleted) != 1 {
+		t.Errorf("expected the in-flight batch to finish, got %v", client.deleted)
+	}
+}
+
+func TestPollerShutdownTimeout(t *testing.T) {
+	client := &fakeSQSClient{}
+	receiver := &fakeSQSReceiver{batches: [][]*sqs.Message{{receivedMessage("1")}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	poller := NewPoller(receiver, "https://sqs.us-west-2.amazonaws.com/123456/my_queue_name", handler)
+	poller.QueueARN = testQueueARN
+	poller.ShutdownTimeout = 10 * time.Millisecond
+	poller.Run(ctx)
+
+	if len(client.deleted) != 0 {
+		t.Errorf("expected the cancelled message to be left on the queue, got %v", client.deleted)
+	}
+}
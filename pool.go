@@ -0,0 +1,115 @@
+package sqsworker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// maxIdleWorkers is the most workers kept waiting for the next batch.  Any more are
+	// stopped as they finish, so that a single large batch doesn't leave thousands of
+	// goroutines behind.
+	maxIdleWorkers = 100
+
+	// workerIdleTimeout is how long a worker waits for more work before stopping.
+	workerIdleTimeout = time.Minute
+)
+
+// poolJob is a message, or a group of messages to handle in order, given to a worker.
+type poolJob struct {
+	ctx     context.Context
+	group   []events.SQSMessage
+	results chan<- outcome
+}
+
+// workerPool keeps the goroutines that handle messages on the Handler across warm
+// invocations, rather than starting one for every message.  A job is given to an idle
+// worker when there is one, or to a new worker when there isn't, so the pool never
+// limits how many messages are handled at once by itself.
+type workerPool struct {
+	run  func(job poolJob)
+	jobs chan poolJob
+	mu   sync.Mutex
+	idle int
+}
+
+func newWorkerPool(run func(job poolJob)) *workerPool {
+	return &workerPool{run: run, jobs: make(chan poolJob)}
+}
+
+// submit hands a job to a worker without waiting for it to finish.
+func (p *workerPool) submit(job poolJob) {
+	select {
+	case p.jobs <- job:
+	default:
+		go p.work(job)
+	}
+}
+
+// work runs jobs until it's been idle for too long, or there are already enough idle
+// workers.
+func (p *workerPool) work(job poolJob) {
+	timer := time.NewTimer(workerIdleTimeout)
+	defer timer.Stop()
+
+	for {
+		p.run(job)
+
+		if !p.park() {
+			return
+		}
+		timer.Reset(workerIdleTimeout)
+
+		select {
+		case job = <-p.jobs:
+			p.unpark()
+		case <-timer.C:
+			p.unpark()
+			return
+		}
+	}
+}
+
+// park counts the worker as idle, unless there are already enough idle workers.
+func (p *workerPool) park() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.idle >= maxIdleWorkers {
+		return false
+	}
+	p.idle++
+	return true
+}
+
+// unpark counts an idle worker as busy again, or stopped.
+func (p *workerPool) unpark() {
+	p.mu.Lock()
+	p.idle--
+	p.mu.Unlock()
+}
+
+// runJob handles a job from the pool, freeing up its slot for another message once it's
+// done.
+func (s *Handler) runJob(job poolJob) {
+	s.handleGroup(job.ctx, job.results, job.group)
+	s.release()
+}
+
+// acquire waits for a slot to handle another message, or message group, in if
+// WithMaxConcurrency is limiting how many are handled at once.
+func (s *Handler) acquire() {
+	if s.slots != nil {
+		s.slots <- struct{}{}
+	}
+}
+
+// release frees up a slot taken by acquire.
+func (s *Handler) release() {
+	if s.slots != nil {
+		<-s.slots
+	}
+}
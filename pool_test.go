@@ -0,0 +1,168 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// countingResolver is a QueueURLResolver that counts how often it's used.
+type countingResolver struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (r *countingResolver) ResolveQueueURL(arn string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	return ARNQueueURLResolver{}.ResolveQueueURL(arn)
+}
+
+// waitIdle waits for the pool to have the given number of idle workers.
+func waitIdle(t *testing.T, p *workerPool, n int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		p.mu.Lock()
+		idle := p.idle
+		p.mu.Unlock()
+
+		if idle == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d idle workers, got %d", n, idle)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWorkerPoolReusesWorkers(t *testing.T) {
+	var wg sync.WaitGroup
+	pool := newWorkerPool(func(job poolJob) { wg.Done() })
+
+	wg.Add(1)
+	pool.submit(poolJob{})
+	wg.Wait()
+	waitIdle(t, pool, 1)
+
+	// the idle worker takes the next job rather than a new one being started
+	wg.Add(1)
+	pool.submit(poolJob{})
+	wg.Wait()
+	waitIdle(t, pool, 1)
+}
+
+func TestWorkerPoolLimitsIdleWorkers(t *testing.T) {
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	pool := newWorkerPool(func(job poolJob) {
+		<-release
+		wg.Done()
+	})
+
+	// keep every worker busy at once so that each job needs a worker of its own
+	jobs := maxIdleWorkers * 2
+	wg.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		pool.submit(poolJob{})
+	}
+	close(release)
+	wg.Wait()
+
+	waitIdle(t, pool, maxIdleWorkers)
+}
+
+func TestHandlerQueueURLs(t *testing.T) {
+	resolver := &countingResolver{}
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithQueueURLResolver(resolver), WithMetrics(&fakeMetrics{}), WithMaxConcurrency(1), WithLogger(nil))
+
+	for i := 0; i < 3; i++ {
+		handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1"), testMessage("2")})
+	}
+
+	// metrics are labelled from the ARN, so the resolver is only used for the deletes
+	if resolver.calls != 6 {
+		t.Errorf("expected the queue URL to be resolved for each delete, got %d", resolver.calls)
+	}
+}
+
+func TestHandlerRetriesQueueURLLookup(t *testing.T) {
+	client := &fakeSQSClient{}
+	getter := &fakeQueueURLGetter{err: errors.New("throttled")}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithQueueURLResolver(NewQueueURLResolver(getter)), WithLogger(nil))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+	getter.err = nil
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("2")})
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("3")})
+
+	// the guessed URL from the failed lookup isn't remembered, but the real one is
+	if getter.calls != 2 {
+		t.Errorf("expected the lookup to be tried again after failing, got %d lookups", getter.calls)
+	}
+}
+
+// benchSQSClient is a PartialSQSClient and PartialSQSBatchDeleter that does nothing, so
+// that benchmarks measure the handler alone.
+type benchSQSClient struct{}
+
+func (benchSQSClient) DeleteMessage(*sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (benchSQSClient) DeleteMessageBatch(*sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	return &sqs.DeleteMessageBatchOutput{}, nil
+}
+
+func (benchSQSClient) ChangeMessageVisibility(*sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+// benchmarkBatch processes a batch of the given size over and over, as a warm function
+// at a high invocation rate would.
+func benchmarkBatch(b *testing.B, size int, opts ...Option) {
+	messages := make([]events.SQSMessage, size)
+	for i := range messages {
+		messages[i] = testMessage(strconv.Itoa(i))
+	}
+
+	handler := NewHandler(benchSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, append([]Option{WithLogger(nil)}, opts...)...)
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := handler.ProcessMessages(ctx, messages); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessMessages10(b *testing.B) {
+	benchmarkBatch(b, 10)
+}
+
+func BenchmarkProcessMessages10BatchDelete(b *testing.B) {
+	benchmarkBatch(b, 10, WithBatchDelete())
+}
+
+func BenchmarkProcessMessages10000(b *testing.B) {
+	benchmarkBatch(b, 10000, WithMaxConcurrency(100))
+}
+
+func BenchmarkProcessMessages10000BatchDelete(b *testing.B) {
+	benchmarkBatch(b, 10000, WithMaxConcurrency(100), WithBatchDelete())
+}
@@ -0,0 +1,57 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// PartialSNSClient is a partial SNS client that can publish messages to a topic.
+type PartialSNSClient interface {
+	PublishWithContext(ctx aws.Context, input *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error)
+}
+
+// publisher publishes the output of successfully processed messages to a topic.
+type publisher struct {
+	client   PartialSNSClient
+	topicARN string
+}
+
+// publishOutput publishes the output the processor gave with SetOutput to the handler's
+// topic.  Nothing is published if there's no topic or the processor gave no output.
+func (s *Handler) publishOutput(ctx context.Context, msg events.SQSMessage) error {
+	if s.publish.client == nil {
+		return nil
+	}
+
+	output, ok, err := outputJSON(ctx)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to encode output of message %s: %w", msg.MessageId, err))
+	}
+	if !ok {
+		return nil
+	}
+
+	input := &sns.PublishInput{
+		TopicArn:          aws.String(s.publish.topicARN),
+		Message:           aws.String(string(output)),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{},
+	}
+	s.correlateSNS(ctx, input.MessageAttributes)
+
+	// FIFO topics need a group and deduplication ID for every message
+	if strings.HasSuffix(s.publish.topicARN, ".fifo") {
+		input.MessageGroupId, input.MessageDeduplicationId = fifoIDs(msg)
+	}
+
+	if _, err := s.publish.client.PublishWithContext(ctx, input); err != nil {
+		return fmt.Errorf("failed to publish output of message %s: %w", msg.MessageId, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// fakeSNSClient is a PartialSNSClient that records the messages published to it.
+type fakeSNSClient struct {
+	mu        sync.Mutex
+	published []*sns.PublishInput
+	err       error
+}
+
+func (c *fakeSNSClient) PublishWithContext(ctx aws.Context, input *sns.PublishInput, opts ...request.Option) (*sns.PublishOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.published = append(c.published, input)
+	return &sns.PublishOutput{}, nil
+}
+
+func TestWithPublishTopic(t *testing.T) {
+	client, snsClient := &fakeSQSClient{}, &fakeSNSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		if msg.MessageId == "result" {
+			SetOutput(ctx, map[string]string{"status": "done"})
+		}
+		return nil
+	}, WithPublishTopic(snsClient, "arn:aws:sns:us-west-2:123456:results"))
+
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("result"), testMessage("quiet")})
+	if completed != 2 || err != nil {
+		t.Errorf("expected both messages to complete, got %d: %v", completed, err)
+	}
+
+	if len(snsClient.published) != 1 {
+		t.Fatalf("expected 1 message to be published, got %d", len(snsClient.published))
+	}
+	published := snsClient.published[0]
+	if *published.TopicArn != "arn:aws:sns:us-west-2:123456:results" || *published.Message != `{"status":"done"}` {
+		t.Errorf("unexpected published message %+v", published)
+	}
+	if published.MessageGroupId != nil {
+		t.Errorf("expected no group ID for a standard topic, got %s", *published.MessageGroupId)
+	}
+}
+
+func TestWithPublishTopicFIFO(t *testing.T) {
+	snsClient := &fakeSNSClient{}
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		SetOutput(ctx, "done")
+		return nil
+	}, WithPublishTopic(snsClient, "arn:aws:sns:us-west-2:123456:results.fifo"))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{fifoMessage("1", "group", "1")})
+
+	if len(snsClient.published) != 1 || *snsClient.published[0].MessageGroupId != "group" ||
+		*snsClient.published[0].MessageDeduplicationId != "1" {
+		t.Errorf("expected the message group to be kept, got %+v", snsClient.published)
+	}
+}
+
+func TestWithPublishTopicFailure(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		SetOutput(ctx, "done")
+		return nil
+	}, WithPublishTopic(&fakeSNSClient{err: errors.New("unavailable")}, "arn:aws:sns:us-west-2:123456:results"))
+
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+	if completed != 0 || len(client.deleted) != 0 {
+		t.Errorf("expected the message to be left on the queue, got %v", client.deleted)
+	}
+}
+
+func TestWithPublishTopicUnencodableOutput(t *testing.T) {
+	client, snsClient := &fakeSQSClient{}, &fakeSNSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		SetOutput(ctx, make(chan int))
+		return nil
+	}, WithPublishTopic(snsClient, "arn:aws:sns:us-west-2:123456:results"), WithDeadLetterQueue(testDeadLetterURL), WithLogger(nil))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+	if len(client.sent) != 1 || len(client.deleted) != 1 || len(snsClient.published) != 0 {
+		t.Errorf("expected the message to be dead-lettered, got %d sent, %v deleted and %d published",
+			len(client.sent), client.deleted, len(snsClient.published))
+	}
+}
+
+func TestWithPublishTopicAfterForward(t *testing.T) {
+	client, snsClient := &fakeSQSClient{sendErr: errors.New("unavailable")}, &fakeSNSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		SetOutput(ctx, "done")
+		return nil
+	}, WithPublishTopic(snsClient, "arn:aws:sns:us-west-2:123456:results"), WithForwardQueue(testDeadLetterURL), WithLogger(nil))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	// nothing is published until the rest of the message has been completed, so that a
+	// redelivery doesn't publish it twice
+	if len(snsClient.published) != 0 || len(client.deleted) != 0 {
+		t.Errorf("expected nothing to be published or deleted, got %d published and %v deleted", len(snsClient.published), client.deleted)
+	}
+}
@@ -0,0 +1,214 @@
+package sqsworker
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// QueueURLResolver finds the URL of a queue from its ARN.
+type QueueURLResolver interface {
+	ResolveQueueURL(arn string) (string, error)
+}
+
+// QueueURLGetter is a partial SQS client that can look up queue URLs.
+type QueueURLGetter interface {
+	GetQueueUrl(input *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error)
+}
+
+// ARNQueueURLResolver is a QueueURLResolver that builds the URL from the parts of the
+// ARN, without making any API calls.  The domain is chosen based on the ARN's partition
+// unless it's overridden.
+type ARNQueueURLResolver struct {
+	// Endpoint replaces the scheme and host of the URL entirely, such as for a VPC
+	// endpoint or LocalStack (e.g. "http://localhost:4566").
+	Endpoint string
+	// Domain replaces the partition's domain, so that URLs take the form
+	// https://sqs.<region>.<Domain>/<account>/<name>.
+	Domain string
+}
+
+// ResolveQueueURL implements the QueueURLResolver interface.
+func (r ARNQueueURLResolver) ResolveQueueURL(arn string) (string, error) {
+	parsed, err := parseQueueARN(arn)
+	if err != nil {
+		return "", err
+	}
+
+	if r.Endpoint != "" {
+		return strings.TrimRight(r.Endpoint, "/") + "/" + parsed.account + "/" + parsed.name, nil
+	}
+
+	domain := r.Domain
+	if domain == "" {
+		domain = parsed.domain()
+	}
+
+	return "https://" + parsed.service + "." + parsed.region + "." + domain + "/" + parsed.account + "/" + parsed.name, nil
+}
+
+// cachingQueueURLResolver looks queue URLs up with GetQueueUrl and remembers them.
+type cachingQueueURLResolver struct {
+	client QueueURLGetter
+	cache  *queueURLCache
+	// fallback builds the URL when the lookup fails, and is given the handler's
+	// endpoint by NewHandler
+	fallback ARNQueueURLResolver
+}
+
+// queueURLCache holds the URLs looked up by a cachingQueueURLResolver.
+type queueURLCache struct {
+	mu   sync.RWMutex
+	urls map[string]string
+}
+
+// NewQueueURLResolver creates a QueueURLResolver that looks up each queue's URL with
+// GetQueueUrl the first time it's seen and caches it for as long as the resolver is
+// around, which for a handler created outside of the Lambda function is every warm
+// invocation.  If the lookup fails, the URL is built from the ARN instead, using the
+// handler's endpoint when the resolver is given to WithQueueURLResolver.
+func NewQueueURLResolver(client QueueURLGetter) QueueURLResolver {
+	return &cachingQueueURLResolver{
+		client:   client,
+		cache:    &queueURLCache{urls: map[string]string{}},
+		fallback: ARNQueueURLResolver{Endpoint: clientEndpoint(client)},
+	}
+}
+
+// withEndpoint returns a resolver sharing the same cache that falls back to building
+// URLs with the given endpoint, so that a handler's endpoint applies to the fallback
+// without changing the resolver for any other handler using it.
+func (r *cachingQueueURLResolver) withEndpoint(endpoint string) *cachingQueueURLResolver {
+	if endpoint == "" || endpoint == r.fallback.Endpoint {
+		return r
+	}
+
+	copied := *r
+	copied.fallback.Endpoint = endpoint
+	return &copied
+}
+
+// ResolveQueueURL implements the QueueURLResolver interface.
+func (r *cachingQueueURLResolver) ResolveQueueURL(arn string) (string, error) {
+	r.cache.mu.RLock()
+	url, ok := r.cache.urls[arn]
+	r.cache.mu.RUnlock()
+	if ok {
+		return url, nil
+	}
+
+	parsed, err := parseQueueARN(arn)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := r.client.GetQueueUrl(&sqs.GetQueueUrlInput{
+		QueueName:              aws.String(parsed.name),
+		QueueOwnerAWSAccountId: aws.String(parsed.account),
+	})
+	if err != nil || aws.StringValue(out.QueueUrl) == "" {
+		// fall back to guessing, but don't cache it so that we try again next time
+		return r.fallback.ResolveQueueURL(arn)
+	}
+
+	url = aws.StringValue(out.QueueUrl)
+
+	r.cache.mu.Lock()
+	r.cache.urls[arn] = url
+	r.cache.mu.Unlock()
+
+	return url, nil
+}
+
+// queueURL returns the URL of the queue with the given ARN.  Any caching is left to the
+// resolver, so that it can choose what's worth remembering.
+func (s *Handler) queueURL(arn string) (string, error) {
+	return s.resolver.ResolveQueueURL(arn)
+}
+
+// queueLabel returns the name of the queue with the given ARN, for metrics.
+func (s *Handler) queueLabel(arn string) string {
+	return queueName(arn)
+}
+
+// partitionDomains maps each AWS partition to the domain its endpoints live under.
+var partitionDomains = map[string]string{
+	"aws":        "amazonaws.com",
+	"aws-cn":     "amazonaws.com.cn",
+	"aws-us-gov": "amazonaws.com",
+	"aws-iso":    "c2s.ic.gov",
+	"aws-iso-b":  "sc2s.sgov.gov",
+	"aws-iso-e":  "cloud.adc-e.uk",
+	"aws-iso-f":  "csp.hci.ic.gov",
+}
+
+// queueARN holds the parts of an SQS queue ARN.
+type queueARN struct {
+	partition string
+	service   string
+	region    string
+	account   string
+	name      string
+}
+
+// parseQueueARN splits a queue ARN of the form arn:partition:sqs:region:account:name.
+func parseQueueARN(arn string) (queueARN, error) {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[1] == "" || parts[3] == "" || parts[4] == "" || parts[5] == "" {
+		return queueARN{}, fmt.Errorf("invalid queue ARN %q", arn)
+	}
+
+	return queueARN{
+		partition: parts[1],
+		service:   parts[2],
+		region:    parts[3],
+		account:   parts[4],
+		name:      parts[5],
+	}, nil
+}
+
+// domain returns the domain of the queue's partition, assuming the commercial
+// partition's domain for any partition we don't know about.
+func (a queueARN) domain() string {
+	if domain, ok := partitionDomains[a.partition]; ok {
+		return domain
+	}
+	return partitionDomains["aws"]
+}
+
+// clientEndpoint returns the endpoint of an SQS client from the SDK if it's been pointed
+// somewhere other than AWS, such as LocalStack, so that queue URLs can be built to match.
+func clientEndpoint(client interface{}) string {
+	c, ok := client.(*sqs.SQS)
+	if !ok || c.Client == nil {
+		return ""
+	}
+
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	for _, domain := range partitionDomains {
+		if strings.HasSuffix(u.Hostname(), "."+domain) {
+			return ""
+		}
+	}
+
+	return c.Endpoint
+}
+
+// convertARN2URL converts the ARN of an SQS queue to the URL version.
+func convertARN2URL(arn string) string {
+	url, _ := ARNQueueURLResolver{}.ResolveQueueURL(arn)
+	return url
+}
+
+// GetURLFromMessage converts the ARN for an SQS message to the queue URL.
+func GetURLFromMessage(msg events.SQSMessage) string {
+	return convertARN2URL(msg.EventSourceARN)
+}
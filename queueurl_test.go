@@ -0,0 +1,176 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// fakeQueueURLGetter is a QueueURLGetter that counts its calls.
+type fakeQueueURLGetter struct {
+	calls int
+	err   error
+}
+
+func (g *fakeQueueURLGetter) GetQueueUrl(input *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	g.calls++
+	if g.err != nil {
+		return nil, g.err
+	}
+	return &sqs.GetQueueUrlOutput{
+		QueueUrl: aws.String("https://localhost/" + *input.QueueOwnerAWSAccountId + "/" + *input.QueueName),
+	}, nil
+}
+
+func TestARNQueueURLResolverInvalid(t *testing.T) {
+	if _, err := (ARNQueueURLResolver{}).ResolveQueueURL("not-an-arn"); err == nil {
+		t.Error("expected an invalid ARN to fail")
+	}
+}
+
+func TestNewQueueURLResolver(t *testing.T) {
+	getter := &fakeQueueURLGetter{}
+	resolver := NewQueueURLResolver(getter)
+
+	for i := 0; i < 3; i++ {
+		url, err := resolver.ResolveQueueURL(testQueueARN)
+		if err != nil || url != "https://localhost/123456/my_queue_name" {
+			t.Fatalf("unexpected url %q and error %v", url, err)
+		}
+	}
+
+	if getter.calls != 1 {
+		t.Errorf("expected the URL to be cached after the first call, got %d calls", getter.calls)
+	}
+}
+
+func TestNewQueueURLResolverFallback(t *testing.T) {
+	getter := &fakeQueueURLGetter{err: errors.New("access denied")}
+	resolver := NewQueueURLResolver(getter)
+
+	url, err := resolver.ResolveQueueURL(testQueueARN)
+	if err != nil || url != "https://sqs.us-west-2.amazonaws.com/123456/my_queue_name" {
+		t.Errorf("expected to fall back to the ARN conversion, got %q and %v", url, err)
+	}
+}
+
+func TestWithQueueURLResolver(t *testing.T) {
+	client := &fakeSQSClient{}
+	getter := &fakeQueueURLGetter{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithQueueURLResolver(NewQueueURLResolver(getter)))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1"), testMessage("2")})
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("3")})
+
+	if len(client.deleted) != 3 || getter.calls != 1 {
+		t.Errorf("expected 3 deletes with a single lookup, got %d deletes and %d lookups", len(client.deleted), getter.calls)
+	}
+}
+
+func TestWithQueueURLResolverAndEndpoint(t *testing.T) {
+	resolver := &countingResolver{}
+
+	for _, opts := range [][]Option{
+		{WithEndpoint("http://localhost:4566"), WithQueueURLResolver(resolver)},
+		{WithQueueURLResolver(resolver), WithEndpoint("http://localhost:4566")},
+	} {
+		handler := NewHandler(&fakeSQSClient{}, nil, opts...)
+		if handler.resolver != resolver {
+			t.Errorf("expected the resolver to be used whatever the order, got %+v", handler.resolver)
+		}
+	}
+
+	handler := NewHandler(&fakeSQSClient{}, nil, WithEndpoint("http://localhost:4566"))
+	if handler.resolver != (ARNQueueURLResolver{Endpoint: "http://localhost:4566"}) {
+		t.Errorf("expected the endpoint to be used for URLs built from ARNs, got %+v", handler.resolver)
+	}
+}
+
+func TestNewQueueURLResolverFallbackEndpoint(t *testing.T) {
+	getter := &fakeQueueURLGetter{err: errors.New("unavailable")}
+	resolver := NewQueueURLResolver(getter)
+	handler := NewHandler(&fakeSQSClient{}, nil, WithQueueURLResolver(resolver), WithEndpoint("http://localhost:4566"))
+
+	url, err := handler.queueURL(testQueueARN)
+	if err != nil || url != "http://localhost:4566/123456/my_queue_name" {
+		t.Errorf("expected the fallback to use the handler's endpoint, got %q and %v", url, err)
+	}
+
+	// the resolver given is left as it was, for any other handlers using it
+	url, _ = resolver.ResolveQueueURL(testQueueARN)
+	if url != "https://sqs.us-west-2.amazonaws.com/123456/my_queue_name" {
+		t.Errorf("expected the resolver itself to be unchanged, got %q", url)
+	}
+
+	// the handler's copy shares the resolver's cache
+	getter.err = nil
+	handler.queueURL(testQueueARN)
+	resolver.ResolveQueueURL(testQueueARN)
+	if getter.calls != 3 {
+		t.Errorf("expected the looked up URL to be cached for both, got %d lookups", getter.calls)
+	}
+}
+
+func TestARNQueueURLResolverPartitions(t *testing.T) {
+	cases := map[string]string{
+		"arn:aws:sqs:us-east-1:123456:queue":            "https://sqs.us-east-1.amazonaws.com/123456/queue",
+		"arn:aws-cn:sqs:cn-north-1:123456:queue":        "https://sqs.cn-north-1.amazonaws.com.cn/123456/queue",
+		"arn:aws-us-gov:sqs:us-gov-west-1:123456:queue": "https://sqs.us-gov-west-1.amazonaws.com/123456/queue",
+		"arn:aws-iso:sqs:us-iso-east-1:123456:q.fifo":   "https://sqs.us-iso-east-1.c2s.ic.gov/123456/q.fifo",
+	}
+
+	for arn, expected := range cases {
+		url, err := ARNQueueURLResolver{}.ResolveQueueURL(arn)
+		if err != nil || url != expected {
+			t.Errorf("expected %s to resolve to %s, got %q and %v", arn, expected, url, err)
+		}
+	}
+}
+
+func TestARNQueueURLResolverOverrides(t *testing.T) {
+	url, _ := ARNQueueURLResolver{Endpoint: "http://localhost:4566/"}.ResolveQueueURL(testQueueARN)
+	if url != "http://localhost:4566/123456/my_queue_name" {
+		t.Errorf("expected the endpoint to be used, got %s", url)
+	}
+
+	url, _ = ARNQueueURLResolver{Domain: "example.com"}.ResolveQueueURL(testQueueARN)
+	if url != "https://sqs.us-west-2.example.com/123456/my_queue_name" {
+		t.Errorf("expected the domain to be used, got %s", url)
+	}
+}
+
+func TestClientEndpoint(t *testing.T) {
+	sess := session.Must(session.NewSession(aws.NewConfig().WithRegion("us-west-2")))
+
+	cases := []struct {
+		name     string
+		client   PartialSQSClient
+		expected string
+	}{
+		{"fake", &fakeSQSClient{}, ""},
+		{"aws", sqs.New(sess), ""},
+		{"vpc endpoint", sqs.New(sess, aws.NewConfig().WithEndpoint("https://vpce-1a2b.sqs.us-west-2.vpce.amazonaws.com")), ""},
+		{"localstack", sqs.New(sess, aws.NewConfig().WithEndpoint("http://localhost:4566")), "http://localhost:4566"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if endpoint := clientEndpoint(c.client); endpoint != c.expected {
+				t.Errorf("expected endpoint %q, got %q", c.expected, endpoint)
+			}
+		})
+	}
+
+	handler := NewHandler(cases[3].client, nil)
+	url, _ := handler.resolver.ResolveQueueURL(testQueueARN)
+	if url != "http://localhost:4566/123456/my_queue_name" {
+		t.Errorf("expected the handler to build URLs with the client's endpoint, got %s", url)
+	}
+}
@@ -0,0 +1,30 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// invoke calls the processor once, waiting for the rate limiter first if there is one
+// and refusing if the circuit breaker is open.
+func (s *Handler) invoke(ctx context.Context, msg events.SQSMessage) error {
+	if s.breaker != nil && !s.breaker.allow() {
+		return classify(ClassRedeliver, ErrCircuitOpen)
+	}
+
+	if s.limiter != nil {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("rate limit wait for message %s: %w", msg.MessageId, err)
+		}
+	}
+
+	err := s.process(ctx, msg)
+
+	if s.breaker != nil {
+		s.breaker.record(classifyError(s.classify, err), err != nil)
+	}
+
+	return err
+}
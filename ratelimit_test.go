@@ -0,0 +1,51 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithRateLimit(50, 2))
+
+	var messages []events.SQSMessage
+	for i := 0; i < 6; i++ {
+		messages = append(messages, testMessage(fmt.Sprint(i)))
+	}
+
+	start := time.Now()
+	completed, err := handler.ProcessMessages(context.Background(), messages)
+	elapsed := time.Since(start)
+
+	if err != nil || completed != 6 {
+		t.Fatalf("expected every message to complete, got %d and %v", completed, err)
+	}
+	// two messages go straight through on the burst, the other four wait 20ms each
+	if elapsed < 70*time.Millisecond {
+		t.Errorf("expected the batch to be rate limited, took %v", elapsed)
+	}
+}
+
+func TestWithRateLimitCancelled(t *testing.T) {
+	calls := 0
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		calls++
+		return nil
+	}, WithRateLimit(0.001, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	completed, _ := handler.ProcessMessages(ctx, []events.SQSMessage{testMessage("1"), testMessage("2")})
+
+	if completed != 1 || calls != 1 {
+		t.Errorf("expected only the burst to be processed, got %d completed after %d calls", completed, calls)
+	}
+}
@@ -0,0 +1,195 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// captureTimeFormat is the timestamp captured events are named with, which sorts in
+// the order they were captured.
+const captureTimeFormat = "20060102T150405.000000000Z"
+
+// WriteEvent writes an event to a new JSON file in the directory given, creating the
+// directory if needed, and returns the path of the file.  Files are named after the
+// time they were written and the first message in the event, so they sort in the order
+// they were written.
+func WriteEvent(dir string, ev events.SQSEvent) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create capture directory: %w", err)
+	}
+
+	name := time.Now().UTC().Format(captureTimeFormat)
+	if len(ev.Records) > 0 {
+		name += "-" + sanitizeFileName(ev.Records[0].MessageId)
+	}
+	path := filepath.Join(dir, name+".json")
+
+	data, err := json.MarshalIndent(ev, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write event: %w", err)
+	}
+
+	return path, nil
+}
+
+// ReadEvent reads an event written by WriteEvent, or any other JSON SQS event such as
+// one copied from a Lambda test event.
+func ReadEvent(path string) (events.SQSEvent, error) {
+	var ev events.SQSEvent
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ev, err
+	}
+
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return ev, fmt.Errorf("failed to parse event %s: %w", path, err)
+	}
+
+	return ev, nil
+}
+
+// ReadEvents reads every event in a directory of events written by WriteEvent, in the
+// order they were written.
+func ReadEvents(dir string) ([]events.SQSEvent, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	evs := make([]events.SQSEvent, 0, len(paths))
+	for _, path := range paths {
+		ev, err := ReadEvent(path)
+		if err != nil {
+			return nil, err
+		}
+		evs = append(evs, ev)
+	}
+
+	return evs, nil
+}
+
+// Replay processes a captured event with the handler in the same way as Handle, for
+// reproducing problems with messages locally.  Replayed messages are deleted from
+// their queue if they complete, as usual, so replay with a client that doesn't reach
+// the real queue, such as the one from sqsworkertest, to leave it untouched.
+func (s *Handler) Replay(ctx context.Context, path string) error {
+	ev, err := ReadEvent(path)
+	if err != nil {
+		return err
+	}
+
+	return s.Handle(ctx, ev)
+}
+
+// Requeue sends the messages in an event to the queue with the given URL, with their
+// original bodies and message attributes, so that they're processed again.  Failure
+// details added by the dead-letter queue are dropped.  Messages sent to a FIFO queue
+// keep their group and are deduplicated by their original ID.
+func Requeue(client PartialSQSSender, queueURL string, ev events.SQSEvent) error {
+	for _, msg := range ev.Records {
+		input := &sqs.SendMessageInput{
+			QueueUrl:          aws.String(queueURL),
+			MessageBody:       aws.String(msg.Body),
+			MessageAttributes: toMessageAttributes(withoutAttribute(msg.MessageAttributes, FailureAttribute)),
+		}
+		setFIFOFields(input, msg)
+
+		if _, err := client.SendMessage(input); err != nil {
+			return fmt.Errorf("failed to requeue message %s: %w", msg.MessageId, err)
+		}
+	}
+
+	return nil
+}
+
+// CaptureQueue receives up to limit messages from a queue and writes each batch received
+// to the directory given as an event, returning the paths of the files written.  The
+// messages aren't deleted, so they become visible on the queue again once their
+// visibility timeout passes.  It stops early if the queue runs out of messages.
+func CaptureQueue(ctx context.Context, client PartialSQSReceiver, queueURL, dir string, limit int) ([]string, error) {
+	poller := NewPoller(client, queueURL, nil)
+	poller.WaitTime = time.Second
+
+	arn, err := poller.lookupQueueARN(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for captured := 0; captured < limit; {
+		poller.BatchSize = limit - captured
+
+		out, err := client.ReceiveMessageWithContext(ctx, poller.receiveInput())
+		if err != nil {
+			return paths, fmt.Errorf("failed to receive messages from %s: %w", queueURL, err)
+		}
+		if len(out.Messages) == 0 {
+			break
+		}
+
+		ev := events.SQSEvent{Records: make([]events.SQSMessage, len(out.Messages))}
+		for i, msg := range out.Messages {
+			ev.Records[i] = toSQSMessage(msg, arn)
+		}
+
+		path, err := WriteEvent(dir, ev)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+		captured += len(out.Messages)
+	}
+
+	return paths, nil
+}
+
+// capture writes a batch to the handler's capture directory, if it has one, or only
+// logs it in a dry run.  Failing to capture a batch doesn't stop it being processed.
+func (s *Handler) capture(messages []events.SQSMessage) {
+	if s.captureDir == "" {
+		return
+	}
+	if s.dryRun.enabled {
+		s.logger.Printf("dry run: would capture %d message(s) to %s", len(messages), s.captureDir)
+		return
+	}
+
+	if _, err := WriteEvent(s.captureDir, events.SQSEvent{Records: messages}); err != nil {
+		s.logger.Printf("failed to capture batch: %v", err)
+	}
+}
+
+// withoutAttribute returns a copy of the attributes without the one named.
+func withoutAttribute(attrs map[string]events.SQSMessageAttribute, name string) map[string]events.SQSMessageAttribute {
+	result := make(map[string]events.SQSMessageAttribute, len(attrs))
+	for k, v := range attrs {
+		if k != name {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// sanitizeFileName replaces the characters in a message ID that can't be used in file names.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, name)
+}
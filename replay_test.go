@@ -0,0 +1,120 @@
+package sqsworker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestWithCapture(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "captured")
+
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithCapture(dir))
+
+	msg := testMessage("1")
+	msg.Body = "hello"
+	handler.Handle(context.Background(), events.SQSEvent{Records: []events.SQSMessage{msg}})
+	handler.Handle(context.Background(), events.SQSEvent{Records: []events.SQSMessage{testMessage("2")}})
+
+	evs, err := ReadEvents(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(evs) != 2 || evs[0].Records[0].Body != "hello" || evs[1].Records[0].MessageId != "2" {
+		t.Errorf("expected both batches to be captured in order, got %+v", evs)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	path, err := WriteEvent(t.TempDir(), events.SQSEvent{Records: []events.SQSMessage{testMessage("a/1")}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(path, "-a_1.json") {
+		t.Errorf("expected the file to be named after the message, got %s", path)
+	}
+
+	var replayed []string
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		replayed = append(replayed, msg.MessageId)
+		return nil
+	})
+
+	if err := handler.Replay(context.Background(), path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(replayed) != 1 || replayed[0] != "a/1" {
+		t.Errorf("expected the captured message to be processed, got %v", replayed)
+	}
+}
+
+func TestReadEventInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(path, []byte("not json"), 0o644)
+
+	if _, err := ReadEvent(path); err == nil {
+		t.Error("expected an error for an invalid event")
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	client := &fakeSQSClient{}
+
+	msg := testMessage("1")
+	msg.Body = "hello"
+	msg.Attributes = map[string]string{"MessageGroupId": "group"}
+	msg.MessageAttributes = map[string]events.SQSMessageAttribute{
+		"type":           {DataType: "String", StringValue: aws.String("order")},
+		FailureAttribute: {DataType: "String", StringValue: aws.String("{}")},
+	}
+
+	queueURL := "https://sqs.us-west-2.amazonaws.com/123456/orders.fifo"
+	if err := Requeue(client, queueURL, events.SQSEvent{Records: []events.SQSMessage{msg}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(client.sent) != 1 {
+		t.Fatalf("expected 1 message to be sent, got %d", len(client.sent))
+	}
+	sent := client.sent[0]
+	if *sent.QueueUrl != queueURL || *sent.MessageBody != "hello" || *sent.MessageGroupId != "group" {
+		t.Errorf("unexpected message %+v", sent)
+	}
+	if _, ok := sent.MessageAttributes[FailureAttribute]; ok || sent.MessageAttributes["type"] == nil {
+		t.Errorf("expected the failure details to be dropped, got %v", sent.MessageAttributes)
+	}
+}
+
+func TestCaptureQueue(t *testing.T) {
+	receiver := &fakeSQSReceiver{
+		batches: [][]*sqs.Message{{receivedMessage("1"), receivedMessage("2")}, {}},
+	}
+
+	dir := t.TempDir()
+	paths, err := CaptureQueue(context.Background(), receiver, "https://sqs.us-west-2.amazonaws.com/123456/my_queue_name", dir, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(paths) != 1 {
+		t.Fatalf("expected 1 batch to be captured, got %v", paths)
+	}
+	if *receiver.inputs[0].MaxNumberOfMessages != 5 {
+		t.Errorf("expected to receive at most 5 messages, got %d", *receiver.inputs[0].MaxNumberOfMessages)
+	}
+
+	ev, err := ReadEvent(paths[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ev.Records) != 2 || ev.Records[0].EventSourceARN != testQueueARN {
+		t.Errorf("unexpected captured event %+v", ev)
+	}
+}
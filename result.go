@@ -0,0 +1,85 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// maxVisibilityTimeout is the longest visibility timeout SQS allows (12 hours).
+const maxVisibilityTimeout = 12 * time.Hour
+
+var (
+	// ErrRetry is reported for messages that asked to be retried without giving a reason.
+	ErrRetry = errors.New("message retry requested")
+	// ErrDeadLetter is reported for messages that were dead-lettered without giving a reason.
+	ErrDeadLetter = errors.New("message dead-lettered")
+)
+
+// Result is an explicit decision made by a ResultProcessor about what should happen
+// to a message once it has been processed.
+type Result struct {
+	err error
+}
+
+// Ack marks the message as complete so that it's deleted from the queue.
+func Ack() Result {
+	return Result{}
+}
+
+// Retry leaves the message on the queue so that it's redelivered once its
+// visibility timeout expires.  The error is optional and reported as the reason.
+func Retry(err error) Result {
+	return Result{err: classify(ClassRedeliver, orDefault(err, ErrRetry))}
+}
+
+// RetryAfter leaves the message on the queue but changes its visibility timeout so
+// that it's redelivered after the given delay.  The error is optional and reported
+// as the reason.
+func RetryAfter(delay time.Duration, err error) Result {
+	return Result{err: RetryIn(delay, err)}
+}
+
+// DeadLetter removes the message from the queue as a permanent failure.  The error
+// is optional and reported as the reason.
+func DeadLetter(err error) Result {
+	return Result{err: Permanent(orDefault(err, ErrDeadLetter))}
+}
+
+// Err returns the error equivalent of the result, which is nil for an Ack.
+func (r Result) Err() error {
+	return r.err
+}
+
+// ResultProcessor is a function that handles a single SQS message from a batch and
+// decides explicitly what should happen to it.
+type ResultProcessor func(ctx context.Context, msg events.SQSMessage) Result
+
+// NewResultHandler creates a Handler using a processor that returns a Result
+// rather than an error.
+func NewResultHandler(sqsClient PartialSQSClient, processor ResultProcessor, opts ...Option) *Handler {
+	return NewHandler(sqsClient, func(ctx context.Context, msg events.SQSMessage) error {
+		return processor(ctx, msg).Err()
+	}, opts...)
+}
+
+// visibilityTimeout converts a delay into a whole number of seconds within the
+// bounds SQS accepts, rounding up so messages are never redelivered early.
+func visibilityTimeout(delay time.Duration) int64 {
+	if delay <= 0 {
+		return 0
+	}
+	if delay > maxVisibilityTimeout {
+		delay = maxVisibilityTimeout
+	}
+	return int64((delay + time.Second - 1) / time.Second)
+}
+
+func orDefault(err, def error) error {
+	if err == nil {
+		return def
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestResultErr(t *testing.T) {
+	reason := errors.New("reason")
+
+	if Ack().Err() != nil {
+		t.Error("expected Ack to have no error")
+	}
+	if err := Retry(nil).Err(); !errors.Is(err, ErrRetry) || classifyError(nil, err) != ClassRedeliver {
+		t.Errorf("expected Retry to be redelivered with ErrRetry, got %v", err)
+	}
+	if err := DeadLetter(reason).Err(); !errors.Is(err, reason) || classifyError(nil, err) != ClassPermanent {
+		t.Errorf("expected DeadLetter to be permanent, got %v", err)
+	}
+	if delay, ok := retryDelay(RetryAfter(time.Minute, reason).Err()); !ok || delay != time.Minute {
+		t.Errorf("expected RetryAfter to carry its delay, got %v", delay)
+	}
+}
+
+func TestVisibilityTimeout(t *testing.T) {
+	cases := map[time.Duration]int64{
+		-time.Second:            0,
+		0:                       0,
+		time.Millisecond:        1,
+		90 * time.Second:        90,
+		1500 * time.Millisecond: 2,
+		24 * time.Hour:          43200,
+	}
+
+	for delay, expected := range cases {
+		if timeout := visibilityTimeout(delay); timeout != expected {
+			t.Errorf("expected %v to give %d seconds, got %d", delay, expected, timeout)
+		}
+	}
+}
+
+func TestNewResultHandler(t *testing.T) {
+	client := &fakeSQSClient{}
+	processor := func(ctx context.Context, msg events.SQSMessage) Result {
+		switch msg.MessageId {
+		case "ack":
+			return Ack()
+		case "retry":
+			return Retry(nil)
+		case "later":
+			return RetryAfter(5*time.Minute, nil)
+		default:
+			return DeadLetter(nil)
+		}
+	}
+
+	handler := NewResultHandler(client, processor)
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		testMessage("ack"),
+		testMessage("retry"),
+		testMessage("later"),
+		testMessage("dead"),
+	})
+
+	if err == nil || completed != 2 {
+		t.Errorf("expected 2 completed messages and an error, got %d and %v", completed, err)
+	}
+	if len(client.deleted) != 2 {
+		t.Errorf("expected the acked and dead-lettered messages to be deleted, got %v", client.deleted)
+	}
+	if len(client.visibility) != 1 || client.visibility["handle-later"] != 300 {
+		t.Errorf("expected the delayed message's visibility to change, got %v", client.visibility)
+	}
+}
@@ -0,0 +1,72 @@
+package sqsworker
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides whether a message that failed processing should be tried
+// again within the same invocation, and how long to wait before doing so.
+type RetryPolicy interface {
+	// Backoff returns the delay to wait before the given retry attempt (starting
+	// at 1) and whether the attempt should be made at all.
+	Backoff(attempt int, err error) (time.Duration, bool)
+}
+
+// ExponentialBackoff is a RetryPolicy that doubles the delay after every attempt.
+type ExponentialBackoff struct {
+	// MaxRetries is the number of times a message is retried after it first fails.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries.  Zero means no cap.
+	MaxDelay time.Duration
+	// Jitter is the fraction (0 to 1) of each delay that is randomised so that
+	// messages failing together don't retry in lockstep.
+	Jitter float64
+}
+
+// Backoff implements the RetryPolicy interface.
+func (b ExponentialBackoff) Backoff(attempt int, err error) (time.Duration, bool) {
+	if attempt < 1 || attempt > b.MaxRetries {
+		return 0, false
+	}
+
+	delay := b.BaseDelay
+	for i := 1; i < attempt; i++ {
+		// stop doubling once we've hit the cap or would overflow
+		if (b.MaxDelay > 0 && delay >= b.MaxDelay) || delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+	}
+
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+
+	if b.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * b.Jitter * float64(delay))
+	}
+
+	return delay, true
+}
+
+// sleep waits for the given duration, returning false if the context is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	policy := ExponentialBackoff{
+		MaxRetries: 4,
+		BaseDelay:  10 * time.Millisecond,
+		MaxDelay:   50 * time.Millisecond,
+	}
+
+	expected := []time.Duration{10, 20, 40, 50}
+	for i, want := range expected {
+		delay, ok := policy.Backoff(i+1, nil)
+		if !ok {
+			t.Fatalf("expected attempt %d to be allowed", i+1)
+		}
+		if delay != want*time.Millisecond {
+			t.Errorf("expected attempt %d to wait %v, got %v", i+1, want*time.Millisecond, delay)
+		}
+	}
+
+	if _, ok := policy.Backoff(5, nil); ok {
+		t.Error("expected attempt 5 to exceed MaxRetries")
+	}
+}
+
+func TestExponentialBackoffJitter(t *testing.T) {
+	policy := ExponentialBackoff{MaxRetries: 1, BaseDelay: time.Second, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		delay, _ := policy.Backoff(1, nil)
+		if delay < 500*time.Millisecond || delay > time.Second {
+			t.Fatalf("expected delay within jitter range, got %v", delay)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	client := &fakeSQSClient{}
+	calls := 0
+	processor := func(ctx context.Context, msg events.SQSMessage) error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	}
+
+	handler := NewHandler(client, processor, WithRetry(ExponentialBackoff{MaxRetries: 3, BaseDelay: time.Millisecond}))
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if completed != 1 || calls != 3 {
+		t.Errorf("expected 1 completed message after 3 calls, got %d after %d", completed, calls)
+	}
+	if len(client.deleted) != 1 {
+		t.Errorf("expected the message to be deleted")
+	}
+}
+
+func TestWithRetryGivesUp(t *testing.T) {
+	client := &fakeSQSClient{}
+	calls := 0
+	processor := func(ctx context.Context, msg events.SQSMessage) error {
+		calls++
+		return errors.New("broken")
+	}
+
+	handler := NewHandler(client, processor, WithRetry(ExponentialBackoff{MaxRetries: 2}))
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{testMessage("1")})
+
+	if err == nil || completed != 0 {
+		t.Errorf("expected the message to fail, got %d completed and error %v", completed, err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	if len(client.deleted) != 0 {
+		t.Errorf("expected the message not to be deleted")
+	}
+}
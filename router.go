@@ -0,0 +1,203 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrNoRoute is reported for messages that don't match any route of a Router.
+var ErrNoRoute = errors.New("no processor registered for message")
+
+// UnmatchedAction is what a Router does with messages that don't match any route.
+type UnmatchedAction int
+
+const (
+	// UnmatchedFail fails the message with ErrNoRoute, leaving it on the queue.
+	UnmatchedFail UnmatchedAction = iota
+	// UnmatchedAck completes the message without processing it.
+	UnmatchedAck
+	// UnmatchedDeadLetter fails the message permanently with ErrNoRoute.
+	UnmatchedDeadLetter
+)
+
+// Matcher is a predicate used by a Router to select a processor for a message.
+type Matcher func(ctx context.Context, msg events.SQSMessage) bool
+
+// Router dispatches messages to different processors based on the value of a message
+// attribute or the content of their body, for queues that carry several kinds of
+// message.  Its Process method is a MessageProcessor, so a Router can be given straight
+// to NewHandler.
+type Router struct {
+	attribute string
+	routes    map[string]MessageProcessor
+	matchers  []matchRoute
+	fallback  MessageProcessor
+	unmatched UnmatchedAction
+}
+
+// matchRoute is a processor chosen by a Matcher.
+type matchRoute struct {
+	match     Matcher
+	processor MessageProcessor
+}
+
+// NewRouter creates a Router that routes on the given message attribute.  The
+// attribute can be left empty for routers that only use matchers.
+func NewRouter(attribute string) *Router {
+	return &Router{
+		attribute: attribute,
+		routes:    map[string]MessageProcessor{},
+	}
+}
+
+// Handle registers the processor for messages whose attribute has the given value.
+func (r *Router) Handle(value string, processor MessageProcessor) {
+	r.routes[value] = processor
+}
+
+// HandleMatch registers the processor for messages accepted by the matcher.  Matchers
+// are tried in the order they're registered, after the attribute routes.
+func (r *Router) HandleMatch(match Matcher, processor MessageProcessor) {
+	r.matchers = append(r.matchers, matchRoute{match: match, processor: processor})
+}
+
+// Fallback sets the processor for messages that don't match any route, including
+// those without the attribute at all.  It takes precedence over the unmatched action.
+func (r *Router) Fallback(processor MessageProcessor) {
+	r.fallback = processor
+}
+
+// Unmatched sets what happens to messages that don't match any route when there's no
+// fallback.  The default is UnmatchedFail.
+func (r *Router) Unmatched(action UnmatchedAction) {
+	r.unmatched = action
+}
+
+// Process dispatches the message to the matching processor.
+func (r *Router) Process(ctx context.Context, msg events.SQSMessage) error {
+	value, ok := attributeValue(msg, r.attribute)
+	if ok {
+		if processor, ok := r.routes[value]; ok {
+			return processor(ctx, msg)
+		}
+	}
+
+	for _, route := range r.matchers {
+		if route.match(ctx, msg) {
+			return route.processor(ctx, msg)
+		}
+	}
+
+	if r.fallback != nil {
+		return r.fallback(ctx, msg)
+	}
+
+	err := ErrNoRoute
+	if r.attribute != "" {
+		err = fmt.Errorf("%w: %s=%q", ErrNoRoute, r.attribute, value)
+	}
+
+	switch r.unmatched {
+	case UnmatchedAck:
+		return nil
+	case UnmatchedDeadLetter:
+		return Permanent(err)
+	default:
+		return err
+	}
+}
+
+// MatchBody creates a Matcher from a predicate over the message body decoded as JSON.
+// Bodies that aren't valid JSON never match.
+func MatchBody(predicate func(body interface{}) bool) Matcher {
+	return func(ctx context.Context, msg events.SQSMessage) bool {
+		var body interface{}
+		if err := json.Unmarshal([]byte(msg.Body), &body); err != nil {
+			return false
+		}
+		return predicate(body)
+	}
+}
+
+// BodyFieldEquals creates a Matcher for JSON bodies where the field at the given path
+// equals the value.  Paths are dot separated object keys or array indexes, optionally
+// starting with "$.", such as "$.order.items.0.sku".
+func BodyFieldEquals(path string, value interface{}) Matcher {
+	expected, err := json.Marshal(value)
+
+	return MatchBody(func(body interface{}) bool {
+		field, ok := lookupPath(body, path)
+		if !ok || err != nil {
+			return false
+		}
+
+		actual, err := json.Marshal(field)
+		return err == nil && bytes.Equal(actual, expected)
+	})
+}
+
+// lookupPath finds the value at a dot separated path within decoded JSON.
+func lookupPath(value interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return value, true
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			value = node[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// attributeValue returns the string value of a message attribute.
+func attributeValue(msg events.SQSMessage, name string) (string, bool) {
+	value, err := Attr(msg).String(name)
+	return value, err == nil
+}
+
+// attributeString returns a function that reads the String message attribute with the
+// given name, or an empty string if the message doesn't have it.
+func attributeString(name string) func(msg events.SQSMessage) string {
+	return func(msg events.SQSMessage) string {
+		value, _ := attributeValue(msg, name)
+		return value
+	}
+}
+
+// bodyString returns a function that reads the string at the given path within a JSON
+// body, or an empty string if there isn't one.
+func bodyString(path string) func(msg events.SQSMessage) string {
+	return func(msg events.SQSMessage) string {
+		var body interface{}
+		if err := json.Unmarshal([]byte(msg.Body), &body); err != nil {
+			return ""
+		}
+
+		value, _ := lookupPath(body, path)
+		str, _ := value.(string)
+		return str
+	}
+}
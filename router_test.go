@@ -0,0 +1,147 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// typedMessage creates a message with a "type" attribute.
+func typedMessage(id, kind string) events.SQSMessage {
+	msg := testMessage(id)
+	msg.MessageAttributes = map[string]events.SQSMessageAttribute{
+		"type": {DataType: "String", StringValue: &kind},
+	}
+	return msg
+}
+
+// recordingMu guards the slices given to recordingProcessor, as the handler may call
+// processors concurrently.
+var recordingMu sync.Mutex
+
+// recordingProcessor returns a processor that records the IDs of the messages it sees.
+func recordingProcessor(seen *[]string) MessageProcessor {
+	return func(ctx context.Context, msg events.SQSMessage) error {
+		recordingMu.Lock()
+		defer recordingMu.Unlock()
+		*seen = append(*seen, msg.MessageId)
+		return nil
+	}
+}
+
+func TestRouter(t *testing.T) {
+	var created, deleted []string
+	router := NewRouter("type")
+	router.Handle("created", recordingProcessor(&created))
+	router.Handle("deleted", recordingProcessor(&deleted))
+
+	ctx := context.Background()
+	router.Process(ctx, typedMessage("1", "created"))
+	router.Process(ctx, typedMessage("2", "deleted"))
+	router.Process(ctx, typedMessage("3", "created"))
+
+	if len(created) != 2 || len(deleted) != 1 {
+		t.Errorf("expected messages to be routed by type, got %v and %v", created, deleted)
+	}
+
+	if err := router.Process(ctx, typedMessage("4", "updated")); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected ErrNoRoute for an unknown type, got %v", err)
+	}
+	if err := router.Process(ctx, testMessage("5")); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected ErrNoRoute for a message without the attribute, got %v", err)
+	}
+}
+
+func TestRouterFallback(t *testing.T) {
+	var fallback []string
+	router := NewRouter("type")
+	router.Handle("created", func(ctx context.Context, msg events.SQSMessage) error { return nil })
+	router.Fallback(recordingProcessor(&fallback))
+
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, router.Process)
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		typedMessage("1", "created"),
+		typedMessage("2", "updated"),
+		testMessage("3"),
+	})
+
+	if err != nil || completed != 3 {
+		t.Errorf("expected every message to complete, got %d and %v", completed, err)
+	}
+	sort.Strings(fallback)
+	if len(fallback) != 2 || fallback[0] != "2" || fallback[1] != "3" {
+		t.Errorf("expected unknown messages to use the fallback, got %v", fallback)
+	}
+}
+
+func TestRouterHandleMatch(t *testing.T) {
+	var orders, refunds, custom []string
+	router := NewRouter("type")
+	router.Handle("refund", recordingProcessor(&refunds))
+	router.HandleMatch(BodyFieldEquals("$.order.status", "paid"), recordingProcessor(&orders))
+	router.HandleMatch(MatchBody(func(body interface{}) bool {
+		_, ok := body.([]interface{})
+		return ok
+	}), recordingProcessor(&custom))
+
+	body := func(id, body string) events.SQSMessage {
+		msg := testMessage(id)
+		msg.Body = body
+		return msg
+	}
+
+	ctx := context.Background()
+	router.Process(ctx, body("1", `{"order":{"status":"paid"}}`))
+	router.Process(ctx, body("2", `[1, 2]`))
+
+	refund := typedMessage("3", "refund")
+	refund.Body = `{"order":{"status":"paid"}}`
+	router.Process(ctx, refund)
+
+	if err := router.Process(ctx, body("4", `{"order":{"status":"pending"}}`)); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected an unmatched body to fail, got %v", err)
+	}
+	if err := router.Process(ctx, body("5", `not json`)); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected an invalid body to fail, got %v", err)
+	}
+
+	if len(orders) != 1 || len(custom) != 1 || len(refunds) != 1 {
+		t.Errorf("unexpected routing %v %v %v", orders, custom, refunds)
+	}
+}
+
+func TestRouterUnmatched(t *testing.T) {
+	router := NewRouter("")
+	msg := testMessage("1")
+
+	router.Unmatched(UnmatchedAck)
+	if err := router.Process(context.Background(), msg); err != nil {
+		t.Errorf("expected unmatched messages to be acked, got %v", err)
+	}
+
+	router.Unmatched(UnmatchedDeadLetter)
+	if err := router.Process(context.Background(), msg); classifyError(nil, err) != ClassPermanent || !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected unmatched messages to be dead-lettered, got %v", err)
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	var body interface{} = map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"sku": "abc", "qty": float64(2)}},
+	}
+
+	if value, ok := lookupPath(body, "items.0.sku"); !ok || value != "abc" {
+		t.Errorf("expected to find the sku, got %v", value)
+	}
+	if _, ok := lookupPath(body, "$.items.1.sku"); ok {
+		t.Error("expected an out of range index not to be found")
+	}
+	if !BodyFieldEquals("items.0.qty", 2)(context.Background(), events.SQSMessage{Body: `{"items":[{"qty":2}]}`}) {
+		t.Error("expected integers to match JSON numbers")
+	}
+}
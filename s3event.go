@@ -0,0 +1,59 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrNotS3Event is reported for messages given to an S3Processor that don't hold an S3
+// event notification.
+var ErrNotS3Event = errors.New("message is not an S3 event notification")
+
+// S3RecordProcessor is a function that handles a single object from an S3 event
+// notification.
+type S3RecordProcessor func(ctx context.Context, record events.S3EventRecord) error
+
+// S3Processor creates a MessageProcessor for queues that receive S3 event
+// notifications.  Each record in the notification is handed to the processor in turn,
+// with the object's key URL-decoded into URLDecodedKey, stopping at the first record
+// that fails.  The test event S3 sends when
+// notifications are first configured is acknowledged without calling the processor,
+// while messages that aren't S3 notifications fail permanently with ErrNotS3Event.
+// Combine it with UnwrapSNS for notifications that pass through an SNS topic.
+func S3Processor(processor S3RecordProcessor) MessageProcessor {
+	return func(ctx context.Context, msg events.SQSMessage) error {
+		var test events.S3TestEvent
+		if err := json.Unmarshal([]byte(msg.Body), &test); err == nil && test.Event == "s3:TestEvent" {
+			return nil
+		}
+
+		var event events.S3Event
+		if err := json.Unmarshal([]byte(msg.Body), &event); err != nil {
+			return Permanent(fmt.Errorf("%w: %v", ErrNotS3Event, err))
+		}
+		if len(event.Records) == 0 {
+			return Permanent(ErrNotS3Event)
+		}
+
+		for _, record := range event.Records {
+			if record.S3.Object.URLDecodedKey == "" {
+				key, err := url.QueryUnescape(record.S3.Object.Key)
+				if err != nil {
+					return Permanent(fmt.Errorf("invalid object key %q: %w", record.S3.Object.Key, err))
+				}
+				record.S3.Object.URLDecodedKey = key
+			}
+
+			if err := processor(ctx, record); err != nil {
+				return fmt.Errorf("s3://%s/%s: %w", record.S3.Bucket.Name, record.S3.Object.URLDecodedKey, err)
+			}
+		}
+
+		return nil
+	}
+}
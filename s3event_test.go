@@ -0,0 +1,66 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const testS3Body = `{"Records":[
+  {"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"uploads"},"object":{"key":"photos/my+cat%281%29.jpg","size":10}}},
+  {"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"uploads"},"object":{"key":"second.jpg","size":20}}}
+]}`
+
+func TestS3Processor(t *testing.T) {
+	var keys []string
+	processor := S3Processor(func(ctx context.Context, record events.S3EventRecord) error {
+		keys = append(keys, record.S3.Object.URLDecodedKey)
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = testS3Body
+	if err := processor(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || keys[0] != "photos/my cat(1).jpg" || keys[1] != "second.jpg" {
+		t.Errorf("expected both decoded keys, got %v", keys)
+	}
+}
+
+func TestS3ProcessorFailure(t *testing.T) {
+	failure := errors.New("failed")
+	processor := S3Processor(func(ctx context.Context, record events.S3EventRecord) error {
+		return failure
+	})
+
+	msg := testMessage("1")
+	msg.Body = testS3Body
+	if err := processor(context.Background(), msg); !errors.Is(err, failure) {
+		t.Errorf("expected the processor's error, got %v", err)
+	}
+}
+
+func TestS3ProcessorOtherMessages(t *testing.T) {
+	processor := S3Processor(func(ctx context.Context, record events.S3EventRecord) error {
+		t.Error("expected the processor not to be called")
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = `{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"uploads"}`
+	if err := processor(context.Background(), msg); err != nil {
+		t.Errorf("expected the test event to be acknowledged, got %v", err)
+	}
+
+	for _, body := range []string{`not json`, `{"hello":"world"}`} {
+		msg.Body = body
+		err := processor(context.Background(), msg)
+		if !errors.Is(err, ErrNotS3Event) || classifyError(nil, err) != ClassPermanent {
+			t.Errorf("expected %s to fail permanently, got %v", body, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ShadowRecorder is implemented by metrics recorders that also count the outcomes of
+// the shadow processor set with WithShadow, such as the StatsDRecorder and the
+// PrometheusRecorder.
+type ShadowRecorder interface {
+	// ShadowSucceeded counts a message the shadow processor handled without an error.
+	ShadowSucceeded(queue string)
+	// ShadowFailed counts a message the shadow processor returned an error for.
+	ShadowFailed(queue string)
+}
+
+// shadow runs a second processor against a sample of messages in the background,
+// without its results having any say in what happens to them.
+type shadow struct {
+	process MessageProcessor
+	rate    float64
+	sample  func() float64
+	wg      sync.WaitGroup
+}
+
+func newShadow(processor MessageProcessor, rate float64) *shadow {
+	return &shadow{process: processor, rate: rate, sample: rand.Float64}
+}
+
+// sampled reports whether the next message should be given to the shadow processor.
+func (sh *shadow) sampled() bool {
+	return sh != nil && sh.rate > 0 && (sh.rate >= 1 || sh.sample() < sh.rate)
+}
+
+// wait blocks until every shadow started so far has finished, so that none are frozen
+// part way through when Lambda freezes the function after the invocation.
+func (sh *shadow) wait() {
+	if sh != nil {
+		sh.wg.Wait()
+	}
+}
+
+// startShadow gives the message to the shadow processor in the background if it's been
+// sampled.  The shadow isn't cancelled along with the message or the batch, but does
+// stop at the batch's deadline, and at the message timeout if there is one.  It has its
+// own output and log fields, so that nothing it does can change the message's.
+func (s *Handler) startShadow(ctx context.Context, msg events.SQSMessage) {
+	if !s.shadow.sampled() {
+		return
+	}
+
+	deadline, ok := ctx.Deadline()
+	if s.messageTimeout > 0 {
+		if timeout := time.Now().Add(s.messageTimeout); !ok || timeout.Before(deadline) {
+			deadline, ok = timeout, true
+		}
+	}
+
+	shadowCtx, cancel := withLogFields(withOutput(context.WithoutCancel(ctx))), context.CancelFunc(func() {})
+	if ok {
+		shadowCtx, cancel = context.WithDeadline(shadowCtx, deadline)
+	}
+
+	s.shadow.wg.Add(1)
+	go func() {
+		defer s.shadow.wg.Done()
+		defer cancel()
+		s.runShadow(shadowCtx, msg)
+	}()
+}
+
+// call runs the shadow processor, turning a panic into an error so that it can't take
+// the rest of the invocation down with it.
+func (sh *shadow) call(ctx context.Context, msg events.SQSMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return sh.process(ctx, msg)
+}
+
+// runShadow calls the shadow processor and records how it went.  Failures, panics
+// included, are only logged and counted.
+func (s *Handler) runShadow(ctx context.Context, msg events.SQSMessage) {
+	start := time.Now()
+	err := s.shadow.call(ctx, msg)
+	d := time.Since(start)
+
+	recorder, _ := s.metrics.(ShadowRecorder)
+	queue := s.queueLabel(msg.EventSourceARN)
+
+	if err != nil {
+		s.logMessage(ctx, "shadow processor failed message %s after %v: %v", msg.MessageId, d, err)
+		if recorder != nil {
+			recorder.ShadowFailed(queue)
+		}
+		return
+	}
+
+	s.logMessage(ctx, "shadow processor handled message %s in %v", msg.MessageId, d)
+	if recorder != nil {
+		recorder.ShadowSucceeded(queue)
+	}
+}
@@ -0,0 +1,167 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithShadow(t *testing.T) {
+	client := &fakeSQSClient{}
+	metrics := &fakeMetrics{}
+	var shadowed atomic.Int32
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithShadow(func(ctx context.Context, msg events.SQSMessage) error {
+		time.Sleep(20 * time.Millisecond)
+		shadowed.Add(1)
+		return errors.New("rewrite isn't ready")
+	}, 1), WithMetrics(metrics), WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), testMessages(3))
+	if completed != 3 || err != nil {
+		t.Errorf("expected the shadow's failures not to affect the batch, got %d: %v", completed, err)
+	}
+	if len(client.deleted) != 3 {
+		t.Errorf("expected every message to be deleted, got %v", client.deleted)
+	}
+	if shadowed.Load() != 3 {
+		t.Errorf("expected the shadow to finish with the batch, got %d", shadowed.Load())
+	}
+	if metrics.counts["shadow failed:my_queue_name"] != 3 {
+		t.Errorf("expected the shadow's failures to be counted, got %v", metrics.counts)
+	}
+}
+
+func TestWithShadowPrimaryFails(t *testing.T) {
+	client := &fakeSQSClient{}
+	metrics := &fakeMetrics{}
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return errors.New("failed")
+	}, WithShadow(func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, 1), WithMetrics(metrics), WithLogger(nil))
+
+	completed, _ := handler.ProcessMessages(context.Background(), testMessages(2))
+	if completed != 0 || len(client.deleted) != 0 {
+		t.Errorf("expected the shadow's successes not to affect the batch, got %d deleting %v", completed, client.deleted)
+	}
+	if metrics.counts["shadow succeeded:my_queue_name"] != 2 {
+		t.Errorf("expected the shadow's successes to be counted, got %v", metrics.counts)
+	}
+}
+
+func TestWithShadowSampling(t *testing.T) {
+	var shadowed atomic.Int32
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithShadow(func(ctx context.Context, msg events.SQSMessage) error {
+		shadowed.Add(1)
+		return nil
+	}, 0.05), WithLogger(nil))
+
+	var n atomic.Int32
+	handler.shadow.sample = func() float64 {
+		return float64(n.Add(1)%20) / 20
+	}
+
+	handler.ProcessMessages(context.Background(), testMessages(40))
+	if shadowed.Load() != 2 {
+		t.Errorf("expected 5%% of messages to be shadowed, got %d of 40", shadowed.Load())
+	}
+}
+
+func TestWithShadowDisabled(t *testing.T) {
+	for _, opt := range []Option{WithShadow(nil, 0), WithDryRunSkipProcessors()} {
+		called := false
+		handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+			return nil
+		}, WithShadow(func(ctx context.Context, msg events.SQSMessage) error {
+			called = true
+			return nil
+		}, 1), opt, WithLogger(nil))
+
+		handler.ProcessMessages(context.Background(), testMessages(1))
+		if called {
+			t.Error("expected the shadow not to be called")
+		}
+	}
+}
+
+func TestWithShadowNotCancelled(t *testing.T) {
+	var uncancelled atomic.Value
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return errors.New("failed")
+	}, WithShadow(func(ctx context.Context, msg events.SQSMessage) error {
+		time.Sleep(20 * time.Millisecond)
+		uncancelled.Store(ctx.Err() == nil)
+		return nil
+	}, 1), WithFailFast(), WithLogger(nil))
+
+	handler.ProcessMessages(context.Background(), testMessages(1))
+	if ok, _ := uncancelled.Load().(bool); !ok {
+		t.Error("expected the shadow not to be cancelled when the batch fails fast")
+	}
+}
+
+func TestWithShadowPanic(t *testing.T) {
+	client := &fakeSQSClient{}
+	metrics := &fakeMetrics{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithShadow(func(ctx context.Context, msg events.SQSMessage) error {
+		panic("not implemented")
+	}, 1), WithMetrics(metrics), WithLogger(nil))
+
+	completed, err := handler.ProcessMessages(context.Background(), testMessages(1))
+	if completed != 1 || err != nil || len(client.deleted) != 1 {
+		t.Errorf("expected the shadow's panic not to affect the batch, got %d: %v", completed, err)
+	}
+	if metrics.counts["shadow failed:my_queue_name"] != 1 {
+		t.Errorf("expected the panic to be counted as a failure, got %v", metrics.counts)
+	}
+}
+
+func TestWithShadowOwnOutput(t *testing.T) {
+	client := &fakeSQSClient{}
+	var buf bytes.Buffer
+	shadowed := make(chan struct{})
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		<-shadowed
+		SetOutput(ctx, "primary")
+		LogWith(ctx, "by", "primary")
+		return nil
+	}, WithShadow(func(ctx context.Context, msg events.SQSMessage) error {
+		defer close(shadowed)
+		SetOutput(ctx, make(chan int))
+		LogWith(ctx, "by", "shadow", "shadow", true)
+		return nil
+	}, 1), WithForwardQueue("https://sqs.us-west-2.amazonaws.com/123456/next"), WithLogger(log.New(&buf, "", 0)))
+
+	completed, err := handler.ProcessMessages(context.Background(), testMessages(1))
+	if completed != 1 || err != nil {
+		t.Fatalf("expected the shadow not to affect the message, got %d: %v", completed, err)
+	}
+	if len(client.sent) != 1 || *client.sent[0].MessageBody != `"primary"` {
+		t.Errorf("expected the primary's output to be forwarded, got %v", client.sent)
+	}
+
+	if !strings.Contains(buf.String(), "message 0 closed") {
+		t.Fatalf("expected the message to be logged as closed, got %q", buf.String())
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "closed") && (!strings.Contains(line, "by=primary") || strings.Contains(line, "shadow=")) {
+			t.Errorf("expected only the primary's log fields, got %q", line)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// snsAttribute is a message attribute as it appears in an SNS envelope.
+type snsAttribute struct {
+	Type  string
+	Value string
+}
+
+// snsEnvelope is the JSON body of a message delivered from SNS without raw message
+// delivery enabled.
+type snsEnvelope struct {
+	events.SNSEntity
+	MessageAttributes map[string]snsAttribute
+}
+
+// UnwrapSNS is a Middleware for queues subscribed to SNS topics without raw message
+// delivery.  When a message's body is an SNS notification, the processor receives the
+// inner message as the body and the notification's message attributes merged into the
+// message's own.  The rest of the envelope, such as the topic ARN and subject, is
+// available through SNSFromContext.  Other messages are passed through untouched.
+func UnwrapSNS(next MessageProcessor) MessageProcessor {
+	return func(ctx context.Context, msg events.SQSMessage) error {
+		var envelope snsEnvelope
+		if err := json.Unmarshal([]byte(msg.Body), &envelope); err != nil ||
+			envelope.Type != "Notification" || envelope.TopicArn == "" {
+			return next(ctx, msg)
+		}
+
+		attrs := make(map[string]events.SQSMessageAttribute, len(msg.MessageAttributes)+len(envelope.MessageAttributes))
+		for name, attr := range msg.MessageAttributes {
+			attrs[name] = attr
+		}
+
+		entity := envelope.SNSEntity
+		entity.MessageAttributes = make(map[string]interface{}, len(envelope.MessageAttributes))
+		for name, attr := range envelope.MessageAttributes {
+			entity.MessageAttributes[name] = map[string]interface{}{"Type": attr.Type, "Value": attr.Value}
+			attrs[name] = fromSNSAttribute(attr)
+		}
+
+		msg.Body = entity.Message
+		msg.MessageAttributes = attrs
+
+		return next(context.WithValue(ctx, snsKey, entity), msg)
+	}
+}
+
+// SNSFromContext returns the SNS envelope of the message being processed, if it was
+// unwrapped by UnwrapSNS.
+func SNSFromContext(ctx context.Context) (events.SNSEntity, bool) {
+	entity, ok := ctx.Value(snsKey).(events.SNSEntity)
+	return entity, ok
+}
+
+// fromSNSAttribute converts an SNS message attribute into an SQS one.  Binary values
+// are base64 encoded in the envelope.
+func fromSNSAttribute(attr snsAttribute) events.SQSMessageAttribute {
+	if attr.Type == "Binary" {
+		if value, err := base64.StdEncoding.DecodeString(attr.Value); err == nil {
+			return events.SQSMessageAttribute{DataType: attr.Type, BinaryValue: value}
+		}
+	}
+
+	value := attr.Value
+	return events.SQSMessageAttribute{DataType: attr.Type, StringValue: &value}
+}
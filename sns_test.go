@@ -0,0 +1,68 @@
+package sqsworker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const testSNSBody = `{
+  "Type": "Notification",
+  "MessageId": "abc",
+  "TopicArn": "arn:aws:sns:us-west-2:123456:orders",
+  "Subject": "New order",
+  "Message": "{\"id\":1}",
+  "Timestamp": "2020-01-01T00:00:00.000Z",
+  "MessageAttributes": {
+    "type": {"Type": "String", "Value": "order.created"},
+    "blob": {"Type": "Binary", "Value": "aGk="}
+  }
+}`
+
+func TestUnwrapSNS(t *testing.T) {
+	var got events.SQSMessage
+	var entity events.SNSEntity
+	var ok bool
+
+	processor := UnwrapSNS(func(ctx context.Context, msg events.SQSMessage) error {
+		got = msg
+		entity, ok = SNSFromContext(ctx)
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = testSNSBody
+	processor(context.Background(), msg)
+
+	if got.Body != `{"id":1}` {
+		t.Errorf("expected the inner message as the body, got %s", got.Body)
+	}
+	if value, _ := attributeValue(got, "type"); value != "order.created" {
+		t.Errorf("expected the SNS attributes to be merged, got %v", got.MessageAttributes)
+	}
+	if string(got.MessageAttributes["blob"].BinaryValue) != "hi" {
+		t.Errorf("expected binary attributes to be decoded, got %v", got.MessageAttributes["blob"])
+	}
+	if !ok || entity.TopicArn != "arn:aws:sns:us-west-2:123456:orders" || entity.Subject != "New order" {
+		t.Errorf("expected the envelope to be in the context, got %+v", entity)
+	}
+}
+
+func TestUnwrapSNSPassthrough(t *testing.T) {
+	var got events.SQSMessage
+	var ok bool
+	processor := UnwrapSNS(func(ctx context.Context, msg events.SQSMessage) error {
+		got = msg
+		_, ok = SNSFromContext(ctx)
+		return nil
+	})
+
+	msg := testMessage("1")
+	msg.Body = `{"Type":"Other"}`
+	processor(context.Background(), msg)
+
+	if got.Body != msg.Body || ok {
+		t.Errorf("expected non-SNS messages to pass through, got %s", got.Body)
+	}
+}
@@ -0,0 +1,200 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Adapter lets a Handler process the records of a Lambda event source other than SQS,
+// such as SNS, Kinesis or DynamoDB Streams, with the same concurrency, retries, hooks,
+// metrics and routing.  Messages converts the event's records into messages, and
+// Response turns the messages that couldn't be completed, if there were any, into the
+// event source's response.
+//
+// Messages from other sources must have their EventSource set to something other than
+// aws:sqs.  Only SQS messages are deleted or have their visibility changed; records
+// from other sources are left for Lambda to keep track of.
+type Adapter[E, R any] interface {
+	Messages(event E) ([]events.SQSMessage, error)
+	Response(err *BatchError) (R, error)
+}
+
+// HandleSource returns a Lambda handler that processes the events of another event
+// source with the handler, using the given adapter.
+//
+//	lambda.Start(sqsworker.HandleSource(handler, sqsworker.KinesisAdapter{}))
+func HandleSource[E, R any](s *Handler, adapter Adapter[E, R]) func(ctx context.Context, event E) (R, error) {
+	return func(ctx context.Context, event E) (R, error) {
+		var response R
+		messages, err := adapter.Messages(event)
+		if err != nil {
+			return response, err
+		}
+
+		completed, err := s.ProcessMessages(ctx, messages)
+
+		// print a status message to our logs
+		s.logger.Printf("%d record(s) received, %d closed", len(messages), completed)
+
+		// anything other than failed messages, such as batch middleware failing, fails the
+		// whole batch
+		var batchErr *BatchError
+		if err != nil && !errors.As(err, &batchErr) {
+			return response, err
+		}
+
+		return adapter.Response(batchErr)
+	}
+}
+
+// fromSQS reports whether a message was received from SQS, rather than converted from
+// another event source by an Adapter.
+func fromSQS(msg events.SQSMessage) bool {
+	return msg.EventSource == "" || msg.EventSource == "aws:sqs"
+}
+
+// SNSAdapter is an Adapter for functions subscribed directly to SNS topics.  Each
+// message has the notification's message as its body, its message attributes, and the
+// topic as its event source ARN.  SNS has no partial batch responses, so any failed
+// message fails the whole event, leaving SNS to retry it.
+type SNSAdapter struct{}
+
+// Messages implements Adapter.
+func (SNSAdapter) Messages(event events.SNSEvent) ([]events.SQSMessage, error) {
+	messages := make([]events.SQSMessage, len(event.Records))
+	for i, record := range event.Records {
+		attrs := make(map[string]events.SQSMessageAttribute, len(record.SNS.MessageAttributes))
+		for name, value := range record.SNS.MessageAttributes {
+			if attr, ok := value.(map[string]interface{}); ok {
+				typ, _ := attr["Type"].(string)
+				value, _ := attr["Value"].(string)
+				attrs[name] = fromSNSAttribute(snsAttribute{Type: typ, Value: value})
+			}
+		}
+
+		messages[i] = events.SQSMessage{
+			MessageId:         record.SNS.MessageID,
+			ReceiptHandle:     record.SNS.MessageID,
+			Body:              record.SNS.Message,
+			Attributes:        map[string]string{"SentTimestamp": timeToMillis(record.SNS.Timestamp)},
+			MessageAttributes: attrs,
+			EventSource:       "aws:sns",
+			EventSourceARN:    record.SNS.TopicArn,
+		}
+	}
+	return messages, nil
+}
+
+// Response implements Adapter.
+func (SNSAdapter) Response(err *BatchError) (struct{}, error) {
+	// a nil *BatchError mustn't be returned as a non-nil error
+	if err != nil {
+		return struct{}{}, err
+	}
+	return struct{}{}, nil
+}
+
+// KinesisAdapter is an Adapter for Kinesis data streams.  Each message has the record's
+// data as its body and its sequence number as its message ID.  The partition key is
+// used as the message group, so that records with the same key are processed in order
+// with WithFIFO.  The event source mapping should have ReportBatchItemFailures enabled.
+type KinesisAdapter struct{}
+
+// Messages implements Adapter.
+func (KinesisAdapter) Messages(event events.KinesisEvent) ([]events.SQSMessage, error) {
+	messages := make([]events.SQSMessage, len(event.Records))
+	for i, record := range event.Records {
+		messages[i] = events.SQSMessage{
+			MessageId:     record.Kinesis.SequenceNumber,
+			ReceiptHandle: record.EventID,
+			Body:          string(record.Kinesis.Data),
+			Attributes: map[string]string{
+				"MessageGroupId": record.Kinesis.PartitionKey,
+				"SequenceNumber": record.Kinesis.SequenceNumber,
+				"SentTimestamp":  timeToMillis(record.Kinesis.ApproximateArrivalTimestamp.Time),
+			},
+			EventSource:    "aws:kinesis",
+			EventSourceARN: record.EventSourceArn,
+			AWSRegion:      record.AwsRegion,
+		}
+	}
+	return messages, nil
+}
+
+// Response implements Adapter.
+func (KinesisAdapter) Response(err *BatchError) (events.KinesisEventResponse, error) {
+	var response events.KinesisEventResponse
+	if err != nil {
+		for _, msgErr := range err.Errors {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.KinesisBatchItemFailure{
+				ItemIdentifier: msgErr.MessageID,
+			})
+		}
+	}
+	return response, nil
+}
+
+// DynamoDBAdapter is an Adapter for DynamoDB streams.  Each message has the whole
+// record as JSON as its body, which can be decoded into an events.DynamoDBEventRecord,
+// and the record's sequence number as its message ID.  The item's keys are used as the
+// message group, so that changes to the same item are processed in order with
+// WithFIFO.  The event source mapping should have ReportBatchItemFailures enabled.
+type DynamoDBAdapter struct{}
+
+// Messages implements Adapter.
+func (DynamoDBAdapter) Messages(event events.DynamoDBEvent) ([]events.SQSMessage, error) {
+	messages := make([]events.SQSMessage, len(event.Records))
+	for i, record := range event.Records {
+		body, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode record %s: %w", record.EventID, err)
+		}
+		keys, err := json.Marshal(record.Change.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode keys of record %s: %w", record.EventID, err)
+		}
+
+		messages[i] = events.SQSMessage{
+			MessageId:     record.Change.SequenceNumber,
+			ReceiptHandle: record.EventID,
+			Body:          string(body),
+			Attributes: map[string]string{
+				"MessageGroupId": string(keys),
+				"SequenceNumber": record.Change.SequenceNumber,
+				"SentTimestamp":  timeToMillis(record.Change.ApproximateCreationDateTime.Time),
+			},
+			EventSource:    "aws:dynamodb",
+			EventSourceARN: record.EventSourceArn,
+			AWSRegion:      record.AWSRegion,
+		}
+	}
+	return messages, nil
+}
+
+// Response implements Adapter.
+func (DynamoDBAdapter) Response(err *BatchError) (events.DynamoDBEventResponse, error) {
+	var response events.DynamoDBEventResponse
+	if err != nil {
+		for _, msgErr := range err.Errors {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: msgErr.MessageID,
+			})
+		}
+	}
+	return response, nil
+}
+
+// timeToMillis formats a time as milliseconds since the epoch, as SQS gives timestamps
+// in message attributes, or returns an empty string for the zero time.
+func timeToMillis(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
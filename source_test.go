@@ -0,0 +1,134 @@
+package sqsworker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const testStreamARN = "arn:aws:kinesis:us-west-2:123456:stream/orders"
+
+func kinesisRecord(seq, key, data string) events.KinesisEventRecord {
+	return events.KinesisEventRecord{
+		EventID:        "shardId-000000000000:" + seq,
+		EventSource:    "aws:kinesis",
+		EventSourceArn: testStreamARN,
+		Kinesis:        events.KinesisRecord{SequenceNumber: seq, PartitionKey: key, Data: []byte(data)},
+	}
+}
+
+func TestHandleSourceKinesis(t *testing.T) {
+	client := &fakeSQSClient{}
+	var bodies []string
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		bodies = append(bodies, msg.Body)
+		if msg.Body == "bad" {
+			return RetryIn(time.Minute, errors.New("failed"))
+		}
+		return nil
+	}, WithBatchDelete(), WithMaxConcurrency(1), WithLogger(nil))
+
+	response, err := HandleSource(handler, KinesisAdapter{})(context.Background(), events.KinesisEvent{
+		Records: []events.KinesisEventRecord{kinesisRecord("1", "a", "ok"), kinesisRecord("2", "b", "bad")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.BatchItemFailures) != 1 || response.BatchItemFailures[0].ItemIdentifier != "2" {
+		t.Errorf("expected the failed record's sequence number to be reported, got %+v", response.BatchItemFailures)
+	}
+	if len(bodies) != 2 || bodies[0] != "ok" {
+		t.Errorf("expected the records' data as the bodies, got %v", bodies)
+	}
+	if len(client.deleted) != 0 || client.batches != 0 || len(client.visibility) != 0 {
+		t.Errorf("expected nothing to be deleted or made visible, got %v, %d batches and %v",
+			client.deleted, client.batches, client.visibility)
+	}
+}
+
+func TestHandleSourceDynamoDB(t *testing.T) {
+	var processed []string
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		var record events.DynamoDBEventRecord
+		if err := json.Unmarshal([]byte(msg.Body), &record); err != nil {
+			return err
+		}
+		processed = append(processed, record.EventName+" "+record.Change.Keys["id"].String())
+		if record.EventName == "INSERT" && record.Change.Keys["id"].String() == "a" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithFIFO(), WithMaxConcurrency(1), WithLogger(nil))
+
+	change := func(id, name, seq string) events.DynamoDBEventRecord {
+		return events.DynamoDBEventRecord{
+			EventID:     name + id,
+			EventName:   name,
+			EventSource: "aws:dynamodb",
+			Change: events.DynamoDBStreamRecord{
+				Keys:           map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute(id)},
+				SequenceNumber: seq,
+			},
+		}
+	}
+
+	response, err := HandleSource(handler, DynamoDBAdapter{})(context.Background(), events.DynamoDBEvent{
+		Records: []events.DynamoDBEventRecord{change("a", "INSERT", "100"), change("b", "INSERT", "101"), change("a", "MODIFY", "102")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the later change to the item that failed is held back to keep the item's changes in order
+	if len(processed) != 2 || processed[0] != "INSERT a" || processed[1] != "INSERT b" {
+		t.Errorf("expected the changes to each item to be processed in order, got %v", processed)
+	}
+	if len(response.BatchItemFailures) != 2 {
+		t.Errorf("expected both changes to the failed item to be reported, got %+v", response.BatchItemFailures)
+	}
+}
+
+func TestHandleSourceSNS(t *testing.T) {
+	client := &fakeSQSClient{}
+	var attrs Attributes
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		attrs = Attr(msg)
+		if msg.Body == "bad" {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithLogger(nil))
+
+	record := func(id, message string) events.SNSEventRecord {
+		return events.SNSEventRecord{EventSource: "aws:sns", SNS: events.SNSEntity{
+			MessageID: id,
+			Message:   message,
+			TopicArn:  "arn:aws:sns:us-west-2:123456:orders",
+			MessageAttributes: map[string]interface{}{
+				"type": map[string]interface{}{"Type": "String", "Value": "order"},
+			},
+		}}
+	}
+	handle := HandleSource(handler, SNSAdapter{})
+
+	if _, err := handle(context.Background(), events.SNSEvent{Records: []events.SNSEventRecord{record("1", "ok")}}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if value, _ := attrs.String("type"); value != "order" {
+		t.Errorf("expected the notification's attributes, got %v", attrs)
+	}
+
+	_, err := handle(context.Background(), events.SNSEvent{Records: []events.SNSEventRecord{record("2", "bad")}})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Errorf("expected a failure to fail the whole event, got %v", err)
+	}
+	if len(client.deleted) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", client.deleted)
+	}
+}
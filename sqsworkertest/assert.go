@@ -0,0 +1,48 @@
+package sqsworkertest
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	sqsworker "github.com/helpfulhuman/lambda-sqs-worker"
+)
+
+// AssertDeleted fails the test if any of the messages weren't deleted through the client.
+func AssertDeleted(t testing.TB, client *SQSClient, msgs ...events.SQSMessage) {
+	t.Helper()
+	for _, msg := range msgs {
+		if !client.WasDeleted(msg.ReceiptHandle) {
+			t.Errorf("expected message %s to be deleted", msg.MessageId)
+		}
+	}
+}
+
+// AssertNotDeleted fails the test if any of the messages were deleted through the client.
+func AssertNotDeleted(t testing.TB, client *SQSClient, msgs ...events.SQSMessage) {
+	t.Helper()
+	for _, msg := range msgs {
+		if client.WasDeleted(msg.ReceiptHandle) {
+			t.Errorf("expected message %s not to be deleted", msg.MessageId)
+		}
+	}
+}
+
+// AssertDeadLettered fails the test unless the message was sent to the dead-letter queue
+// with the given URL, and returns the failure details that were attached to it.
+func AssertDeadLettered(t testing.TB, client *SQSClient, queueURL string, msg events.SQSMessage) sqsworker.FailureDetails {
+	t.Helper()
+	details, ok := client.DeadLettered(queueURL, msg.MessageId)
+	if !ok {
+		t.Errorf("expected message %s to be dead-lettered to %s", msg.MessageId, queueURL)
+	}
+	return details
+}
+
+// AssertNotDeadLettered fails the test if the message was sent to the dead-letter queue
+// with the given URL.
+func AssertNotDeadLettered(t testing.TB, client *SQSClient, queueURL string, msg events.SQSMessage) {
+	t.Helper()
+	if _, ok := client.DeadLettered(queueURL, msg.MessageId); ok {
+		t.Errorf("expected message %s not to be dead-lettered to %s", msg.MessageId, queueURL)
+	}
+}
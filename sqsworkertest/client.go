@@ -0,0 +1,203 @@
+// Package sqsworkertest provides utilities for testing processors and handlers built
+// with sqsworker: a fake SQS client that records what the handler did, builders for
+// SQS messages and events, and assertions over the recorded calls.
+package sqsworkertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	sqsworker "github.com/helpfulhuman/lambda-sqs-worker"
+)
+
+// Deletion is a message deleted through the fake client.
+type Deletion struct {
+	QueueURL      string
+	ReceiptHandle string
+}
+
+// VisibilityChange is a change to the visibility timeout of a message made through
+// the fake client.
+type VisibilityChange struct {
+	QueueURL      string
+	ReceiptHandle string
+	Timeout       int64
+}
+
+// SQSClient is a fake sqsworker.PartialSQSClient that records every call made to it.
+// Failures can be injected by setting the error functions, which are called for each
+// message before the call is recorded.  It's safe for concurrent use, but the error
+// functions should be set before the client is used.
+type SQSClient struct {
+	// DeleteErr returns the error to fail deleting a message with, both individually
+	// and in a batch, or nil to let it succeed.
+	DeleteErr func(queueURL, receiptHandle string) error
+	// SendErr returns the error to fail sending a message with, or nil to let it succeed.
+	SendErr func(input *sqs.SendMessageInput) error
+	// VisibilityErr returns the error to fail changing a message's visibility with, or
+	// nil to let it succeed.
+	VisibilityErr func(queueURL, receiptHandle string) error
+
+	mu         sync.Mutex
+	deleted    []Deletion
+	sent       []*sqs.SendMessageInput
+	visibility []VisibilityChange
+}
+
+// NewSQSClient creates a fake SQS client that succeeds at everything.
+func NewSQSClient() *SQSClient {
+	return &SQSClient{}
+}
+
+var (
+	_ sqsworker.PartialSQSClient       = (*SQSClient)(nil)
+	_ sqsworker.PartialSQSBatchDeleter = (*SQSClient)(nil)
+	_ sqsworker.PartialSQSSender       = (*SQSClient)(nil)
+)
+
+// DeleteMessage records the deletion of a message.
+func (c *SQSClient) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	if err := c.deleteErr(aws.StringValue(input.QueueUrl), aws.StringValue(input.ReceiptHandle)); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, Deletion{
+		QueueURL:      aws.StringValue(input.QueueUrl),
+		ReceiptHandle: aws.StringValue(input.ReceiptHandle),
+	})
+
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+// DeleteMessageBatch records the deletion of each message in the batch, reporting any
+// that DeleteErr fails as failed entries.
+func (c *SQSClient) DeleteMessageBatch(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	queueURL := aws.StringValue(input.QueueUrl)
+	out := &sqs.DeleteMessageBatchOutput{}
+
+	for _, entry := range input.Entries {
+		receiptHandle := aws.StringValue(entry.ReceiptHandle)
+
+		if err := c.deleteErr(queueURL, receiptHandle); err != nil {
+			code, senderFault := "InternalError", false
+			if aerr, ok := err.(awserr.Error); ok {
+				code, senderFault = aerr.Code(), aerr.Code() == sqs.ErrCodeReceiptHandleIsInvalid
+			}
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{
+				Id:          entry.Id,
+				Code:        aws.String(code),
+				Message:     aws.String(err.Error()),
+				SenderFault: aws.Bool(senderFault),
+			})
+			continue
+		}
+
+		c.mu.Lock()
+		c.deleted = append(c.deleted, Deletion{QueueURL: queueURL, ReceiptHandle: receiptHandle})
+		c.mu.Unlock()
+		out.Successful = append(out.Successful, &sqs.DeleteMessageBatchResultEntry{Id: entry.Id})
+	}
+
+	return out, nil
+}
+
+// ChangeMessageVisibility records the change to a message's visibility timeout.
+func (c *SQSClient) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	queueURL, receiptHandle := aws.StringValue(input.QueueUrl), aws.StringValue(input.ReceiptHandle)
+	if c.VisibilityErr != nil {
+		if err := c.VisibilityErr(queueURL, receiptHandle); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.visibility = append(c.visibility, VisibilityChange{
+		QueueURL:      queueURL,
+		ReceiptHandle: receiptHandle,
+		Timeout:       aws.Int64Value(input.VisibilityTimeout),
+	})
+
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+// SendMessage records the message that was sent.
+func (c *SQSClient) SendMessage(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	if c.SendErr != nil {
+		if err := c.SendErr(input); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, input)
+
+	return &sqs.SendMessageOutput{MessageId: aws.String(fmt.Sprintf("sent-%d", len(c.sent)))}, nil
+}
+
+// Deleted returns every message deleted so far, in the order they were deleted.
+func (c *SQSClient) Deleted() []Deletion {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Deletion(nil), c.deleted...)
+}
+
+// Sent returns every message sent so far, in the order they were sent.
+func (c *SQSClient) Sent() []*sqs.SendMessageInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*sqs.SendMessageInput(nil), c.sent...)
+}
+
+// VisibilityChanges returns every visibility timeout change made so far, in order.
+func (c *SQSClient) VisibilityChanges() []VisibilityChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]VisibilityChange(nil), c.visibility...)
+}
+
+// WasDeleted reports whether the message with the given receipt handle was deleted.
+func (c *SQSClient) WasDeleted(receiptHandle string) bool {
+	for _, d := range c.Deleted() {
+		if d.ReceiptHandle == receiptHandle {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLettered returns the failure details attached to the message with the given ID
+// when it was sent to the dead-letter queue with the given URL.
+func (c *SQSClient) DeadLettered(queueURL, messageID string) (sqsworker.FailureDetails, bool) {
+	for _, input := range c.Sent() {
+		if aws.StringValue(input.QueueUrl) != queueURL {
+			continue
+		}
+
+		attr, ok := input.MessageAttributes[sqsworker.FailureAttribute]
+		if !ok {
+			continue
+		}
+
+		var details sqsworker.FailureDetails
+		if err := json.Unmarshal([]byte(aws.StringValue(attr.StringValue)), &details); err == nil && details.MessageID == messageID {
+			return details, true
+		}
+	}
+
+	return sqsworker.FailureDetails{}, false
+}
+
+func (c *SQSClient) deleteErr(queueURL, receiptHandle string) error {
+	if c.DeleteErr == nil {
+		return nil
+	}
+	return c.DeleteErr(queueURL, receiptHandle)
+}
@@ -0,0 +1,81 @@
+package sqsworkertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	sqsworker "github.com/helpfulhuman/lambda-sqs-worker"
+)
+
+const dlqURL = "https://sqs.us-east-1.amazonaws.com/123456789012/test-dlq"
+
+func TestSQSClient(t *testing.T) {
+	client := NewSQSClient()
+	handler := sqsworker.NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		switch msg.Body {
+		case "bad":
+			return sqsworker.Permanent(errors.New("bad payload"))
+		case "later":
+			return errors.New("try again")
+		}
+		return nil
+	}, sqsworker.WithDeadLetterQueue(dlqURL))
+
+	good, bad, later := NewMessage("1", "good"), NewMessage("2", "bad"), NewMessage("3", "later")
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{good, bad, later})
+
+	AssertDeleted(t, client, good, bad)
+	AssertNotDeleted(t, client, later)
+	AssertNotDeadLettered(t, client, dlqURL, good)
+
+	details := AssertDeadLettered(t, client, dlqURL, bad)
+	if details.Error != "bad payload" || details.SourceQueueARN != QueueARN {
+		t.Errorf("unexpected failure details %+v", details)
+	}
+
+	for _, d := range client.Deleted() {
+		if d.QueueURL != QueueURL {
+			t.Errorf("expected deletes from %s, got %s", QueueURL, d.QueueURL)
+		}
+	}
+}
+
+func TestSQSClientErrors(t *testing.T) {
+	client := NewSQSClient()
+	client.DeleteErr = func(queueURL, receiptHandle string) error {
+		if receiptHandle == "receipt-2" {
+			return awserr.New("InternalError", "try again", nil)
+		}
+		return nil
+	}
+	handler := sqsworker.NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, sqsworker.WithBatchDelete())
+
+	ok, failing := NewMessage("1", ""), NewMessage("2", "")
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{ok, failing})
+
+	if completed != 1 {
+		t.Errorf("expected 1 completed message, got %d", completed)
+	}
+	AssertDeleted(t, client, ok)
+	AssertNotDeleted(t, client, failing)
+}
+
+func TestSQSClientVisibility(t *testing.T) {
+	client := NewSQSClient()
+	handler := sqsworker.NewResultHandler(client, func(ctx context.Context, msg events.SQSMessage) sqsworker.Result {
+		return sqsworker.RetryAfter(30*time.Second, errors.New("not yet"))
+	})
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{NewMessage("1", "")})
+
+	changes := client.VisibilityChanges()
+	if len(changes) != 1 || changes[0].ReceiptHandle != "receipt-1" || changes[0].Timeout != 30 {
+		t.Errorf("unexpected visibility changes %+v", changes)
+	}
+}
@@ -0,0 +1,138 @@
+package sqsworkertest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// QueueARN is the ARN of the queue that built messages come from by default.
+	QueueARN = "arn:aws:sqs:us-east-1:123456789012:test-queue"
+	// QueueURL is the URL sqsworker builds for QueueARN.
+	QueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/test-queue"
+)
+
+// MessageOption customises a message built by NewMessage.
+type MessageOption func(*events.SQSMessage)
+
+// NewMessage builds a message from QueueARN with the given ID and body, a receipt
+// handle of "receipt-" followed by the ID, and a receive count of 1.  The options are
+// applied in order.
+func NewMessage(id, body string, opts ...MessageOption) events.SQSMessage {
+	now := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+
+	msg := events.SQSMessage{
+		MessageId:     id,
+		ReceiptHandle: "receipt-" + id,
+		Body:          body,
+		Attributes: map[string]string{
+			"ApproximateReceiveCount":          "1",
+			"SentTimestamp":                    now,
+			"ApproximateFirstReceiveTimestamp": now,
+		},
+		MessageAttributes: map[string]events.SQSMessageAttribute{},
+		EventSource:       "aws:sqs",
+		EventSourceARN:    QueueARN,
+		AWSRegion:         "us-east-1",
+	}
+
+	for _, opt := range opts {
+		opt(&msg)
+	}
+
+	return msg
+}
+
+// NewEvent builds an SQS event holding the given messages.
+func NewEvent(msgs ...events.SQSMessage) events.SQSEvent {
+	return events.SQSEvent{Records: msgs}
+}
+
+// WithQueueARN sets the ARN of the queue the message came from.
+func WithQueueARN(arn string) MessageOption {
+	return func(msg *events.SQSMessage) {
+		msg.EventSourceARN = arn
+	}
+}
+
+// WithAttribute adds a String message attribute.
+func WithAttribute(name, value string) MessageOption {
+	return func(msg *events.SQSMessage) {
+		msg.MessageAttributes[name] = events.SQSMessageAttribute{DataType: "String", StringValue: &value}
+	}
+}
+
+// WithNumberAttribute adds a Number message attribute.
+func WithNumberAttribute(name, value string) MessageOption {
+	return func(msg *events.SQSMessage) {
+		msg.MessageAttributes[name] = events.SQSMessageAttribute{DataType: "Number", StringValue: &value}
+	}
+}
+
+// WithBinaryAttribute adds a Binary message attribute.
+func WithBinaryAttribute(name string, value []byte) MessageOption {
+	return func(msg *events.SQSMessage) {
+		msg.MessageAttributes[name] = events.SQSMessageAttribute{DataType: "Binary", BinaryValue: value}
+	}
+}
+
+// WithSystemAttribute sets one of the message's system attributes, such as
+// SentTimestamp.
+func WithSystemAttribute(name, value string) MessageOption {
+	return func(msg *events.SQSMessage) {
+		msg.Attributes[name] = value
+	}
+}
+
+// WithReceiveCount sets the number of times the message has been received.
+func WithReceiveCount(n int) MessageOption {
+	return WithSystemAttribute("ApproximateReceiveCount", strconv.Itoa(n))
+}
+
+// WithFIFO gives the message the system attributes of a message from a FIFO queue,
+// and adds ".fifo" to the name of its queue if it's missing.
+func WithFIFO(groupID, deduplicationID, sequenceNumber string) MessageOption {
+	return func(msg *events.SQSMessage) {
+		msg.Attributes["MessageGroupId"] = groupID
+		msg.Attributes["MessageDeduplicationId"] = deduplicationID
+		msg.Attributes["SequenceNumber"] = sequenceNumber
+		if !strings.HasSuffix(msg.EventSourceARN, ".fifo") {
+			msg.EventSourceARN += ".fifo"
+		}
+	}
+}
+
+// WithSNSEnvelope wraps the message's body, and any message attributes added so far,
+// in the envelope SNS delivers to queues subscribed without raw message delivery.
+func WithSNSEnvelope(topicARN string) MessageOption {
+	return func(msg *events.SQSMessage) {
+		attrs := make(map[string]interface{}, len(msg.MessageAttributes))
+		for name, attr := range msg.MessageAttributes {
+			value := ""
+			if attr.StringValue != nil {
+				value = *attr.StringValue
+			} else if attr.BinaryValue != nil {
+				value = base64.StdEncoding.EncodeToString(attr.BinaryValue)
+			}
+			attrs[name] = map[string]string{"Type": attr.DataType, "Value": value}
+		}
+
+		envelope, _ := json.Marshal(events.SNSEntity{
+			Type:              "Notification",
+			MessageID:         msg.MessageId + "-sns",
+			TopicArn:          topicARN,
+			Message:           msg.Body,
+			Timestamp:         time.Now().UTC(),
+			SignatureVersion:  "1",
+			MessageAttributes: attrs,
+		})
+
+		msg.Body = string(envelope)
+		msg.MessageAttributes = map[string]events.SQSMessageAttribute{}
+	}
+}
@@ -0,0 +1,57 @@
+package sqsworkertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	sqsworker "github.com/helpfulhuman/lambda-sqs-worker"
+)
+
+func TestNewMessage(t *testing.T) {
+	msg := NewMessage("1", "hello",
+		WithAttribute("type", "greeting"),
+		WithReceiveCount(3),
+		WithFIFO("group", "dedup", "10"),
+	)
+
+	if msg.ReceiptHandle != "receipt-1" || msg.Body != "hello" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if *msg.MessageAttributes["type"].StringValue != "greeting" {
+		t.Errorf("expected the type attribute to be set, got %+v", msg.MessageAttributes)
+	}
+	if msg.Attributes["MessageGroupId"] != "group" || msg.EventSourceARN != QueueARN+".fifo" {
+		t.Errorf("expected a FIFO message, got %+v", msg)
+	}
+
+	var count int
+	handler := sqsworker.NewHandler(NewSQSClient(), func(ctx context.Context, msg events.SQSMessage) error {
+		count = sqsworker.ReceiveCount(ctx)
+		return nil
+	})
+	handler.Handle(context.Background(), NewEvent(msg))
+
+	if count != 3 {
+		t.Errorf("expected a receive count of 3, got %d", count)
+	}
+}
+
+func TestWithSNSEnvelope(t *testing.T) {
+	msg := NewMessage("1", "hello", WithAttribute("type", "greeting"), WithSNSEnvelope("arn:aws:sns:us-east-1:123456789012:topic"))
+
+	var body, attr, topic string
+	processor := sqsworker.UnwrapSNS(func(ctx context.Context, msg events.SQSMessage) error {
+		body, attr = msg.Body, *msg.MessageAttributes["type"].StringValue
+		entity, _ := sqsworker.SNSFromContext(ctx)
+		topic = entity.TopicArn
+		return nil
+	})
+
+	if err := processor(context.Background(), msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body != "hello" || attr != "greeting" || topic != "arn:aws:sns:us-east-1:123456789012:topic" {
+		t.Errorf("unexpected unwrapped message %q %q %q", body, attr, topic)
+	}
+}
@@ -0,0 +1,30 @@
+package sqsworker
+
+import (
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Start creates a handler for the processor with NewHandlerFromEnv and starts Lambda
+// with it, in place of the usual main function.  Lambda is started with
+// HandleWithResponse if WithBatchResponse is given, or SQSWORKER_BATCH_RESPONSE is true,
+// and Handle otherwise.  It exits if the handler can't be created and otherwise never
+// returns.
+func Start(processor MessageProcessor, opts ...Option) {
+	handler, err := NewHandlerFromEnv(processor, opts...)
+	if err != nil {
+		log.Fatalf("failed to create SQS worker: %v", err)
+	}
+
+	lambda.Start(handler.LambdaHandler())
+}
+
+// LambdaHandler returns the method Lambda should be started with: HandleWithResponse
+// when the handler was created with WithBatchResponse, or Handle otherwise.
+func (s *Handler) LambdaHandler() interface{} {
+	if s.batchResponse {
+		return s.HandleWithResponse
+	}
+	return s.Handle
+}
@@ -0,0 +1,22 @@
+package sqsworker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestLambdaHandler(t *testing.T) {
+	processor := func(ctx context.Context, msg events.SQSMessage) error { return nil }
+
+	handler := NewHandler(&fakeSQSClient{}, processor).LambdaHandler()
+	if _, ok := handler.(func(context.Context, events.SQSEvent) error); !ok {
+		t.Errorf("expected Handle, got %T", handler)
+	}
+
+	handler = NewHandler(&fakeSQSClient{}, processor, WithBatchResponse()).LambdaHandler()
+	if _, ok := handler.(func(context.Context, events.SQSEvent) (events.SQSEventResponse, error)); !ok {
+		t.Errorf("expected HandleWithResponse, got %T", handler)
+	}
+}
@@ -0,0 +1,124 @@
+package sqsworker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+const (
+	// TaskTokenAttribute is the message attribute task tokens are read from by default.
+	TaskTokenAttribute = "TaskToken"
+
+	// TaskFailureError is the error name tasks are failed with.
+	TaskFailureError = "sqsworker.MessageFailed"
+
+	// maxTaskFailureCause is the longest cause SendTaskFailure accepts.
+	maxTaskFailureCause = 32768
+)
+
+// PartialSFNClient is a partial Step Functions client that can report the result of
+// tasks that are waiting for a callback.
+type PartialSFNClient interface {
+	SendTaskSuccessWithContext(ctx aws.Context, input *sfn.SendTaskSuccessInput, opts ...request.Option) (*sfn.SendTaskSuccessOutput, error)
+	SendTaskFailureWithContext(ctx aws.Context, input *sfn.SendTaskFailureInput, opts ...request.Option) (*sfn.SendTaskFailureOutput, error)
+}
+
+// TaskTokenSource finds the Step Functions task token carried by a message, returning
+// an empty string if it doesn't have one.
+type TaskTokenSource func(msg events.SQSMessage) string
+
+// TaskTokenFromAttribute reads task tokens from the message attribute with the given name.
+func TaskTokenFromAttribute(name string) TaskTokenSource {
+	return TaskTokenSource(attributeString(name))
+}
+
+// TaskTokenFromBody reads task tokens from the field of a JSON body at the given path,
+// such as "$.taskToken".
+func TaskTokenFromBody(path string) TaskTokenSource {
+	return TaskTokenSource(bodyString(path))
+}
+
+// taskCallback reports the result of processing messages that carry task tokens.
+type taskCallback struct {
+	client PartialSFNClient
+	token  TaskTokenSource
+}
+
+// succeedTask sends a task success for the message if it carries a task token, with any
+// output the processor gave with SetOutput.  An error means the callback should be tried
+// again, so the message is left on the queue.
+func (s *Handler) succeedTask(ctx context.Context, msg events.SQSMessage) error {
+	token := s.taskToken(msg)
+	if token == "" {
+		return nil
+	}
+
+	output, ok, err := outputJSON(ctx)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to encode task output for message %s: %w", msg.MessageId, err))
+	}
+	if !ok {
+		output = []byte("{}")
+	}
+
+	_, err = s.tasks.client.SendTaskSuccessWithContext(ctx, &sfn.SendTaskSuccessInput{
+		TaskToken: &token,
+		Output:    aws.String(string(output)),
+	})
+
+	return s.taskCallbackError(ctx, msg, err)
+}
+
+// failTask sends a task failure for a message that failed permanently if it carries a
+// task token.  Failing to do so is only logged, as the message won't ever succeed.
+func (s *Handler) failTask(ctx context.Context, msg events.SQSMessage, cause error) {
+	token := s.taskToken(msg)
+	if token == "" {
+		return
+	}
+
+	reason := cause.Error()
+	if len(reason) > maxTaskFailureCause {
+		reason = reason[:maxTaskFailureCause]
+	}
+
+	_, err := s.tasks.client.SendTaskFailureWithContext(ctx, &sfn.SendTaskFailureInput{
+		TaskToken: &token,
+		Error:     aws.String(TaskFailureError),
+		Cause:     &reason,
+	})
+	if err != nil {
+		s.logMessage(ctx, "failed to send task failure for message %s: %v", msg.MessageId, err)
+	}
+}
+
+func (s *Handler) taskToken(msg events.SQSMessage) string {
+	if s.tasks.client == nil {
+		return ""
+	}
+	return s.tasks.token(msg)
+}
+
+// taskCallbackError decides what to do when a task callback fails.  Tasks that have
+// timed out or no longer exist can never be completed, so their messages are closed.
+func (s *Handler) taskCallbackError(ctx context.Context, msg events.SQSMessage, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case sfn.ErrCodeTaskTimedOut, sfn.ErrCodeTaskDoesNotExist, sfn.ErrCodeInvalidToken:
+			s.logMessage(ctx, "task for message %s can't be completed: %v", msg.MessageId, err)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to send task success for message %s: %w", msg.MessageId, err)
+}
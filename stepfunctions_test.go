@@ -0,0 +1,159 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// fakeSFNClient is a PartialSFNClient that records the callbacks sent to it.
+type fakeSFNClient struct {
+	mu         sync.Mutex
+	successes  []*sfn.SendTaskSuccessInput
+	failures   []*sfn.SendTaskFailureInput
+	successErr error
+}
+
+func (c *fakeSFNClient) SendTaskSuccessWithContext(ctx aws.Context, input *sfn.SendTaskSuccessInput, opts ...request.Option) (*sfn.SendTaskSuccessOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.successErr != nil {
+		return nil, c.successErr
+	}
+	c.successes = append(c.successes, input)
+	return &sfn.SendTaskSuccessOutput{}, nil
+}
+
+func (c *fakeSFNClient) SendTaskFailureWithContext(ctx aws.Context, input *sfn.SendTaskFailureInput, opts ...request.Option) (*sfn.SendTaskFailureOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = append(c.failures, input)
+	return &sfn.SendTaskFailureOutput{}, nil
+}
+
+func taskMessage(id, token string) events.SQSMessage {
+	msg := testMessage(id)
+	msg.MessageAttributes = map[string]events.SQSMessageAttribute{
+		TaskTokenAttribute: {DataType: "String", StringValue: aws.String(token)},
+	}
+	return msg
+}
+
+func TestWithTaskTokens(t *testing.T) {
+	client, sfnClient := &fakeSQSClient{}, &fakeSFNClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		switch msg.MessageId {
+		case "output":
+			SetOutput(ctx, map[string]int{"total": 3})
+		case "bad":
+			return Permanent(errors.New("bad payload"))
+		case "later":
+			return errors.New("try again")
+		}
+		return nil
+	}, WithTaskTokens(sfnClient, nil))
+
+	completed, _ := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		taskMessage("output", "token-output"),
+		taskMessage("plain", "token-plain"),
+		taskMessage("bad", "token-bad"),
+		taskMessage("later", "token-later"),
+		testMessage("untracked"),
+	})
+	if completed != 4 {
+		t.Errorf("expected 4 completed messages, got %d", completed)
+	}
+
+	outputs := map[string]string{}
+	for _, input := range sfnClient.successes {
+		outputs[*input.TaskToken] = *input.Output
+	}
+	if len(outputs) != 2 || outputs["token-output"] != `{"total":3}` || outputs["token-plain"] != "{}" {
+		t.Errorf("unexpected task successes %v", outputs)
+	}
+
+	if len(sfnClient.failures) != 1 || *sfnClient.failures[0].TaskToken != "token-bad" ||
+		*sfnClient.failures[0].Cause != "bad payload" || *sfnClient.failures[0].Error != TaskFailureError {
+		t.Errorf("unexpected task failures %+v", sfnClient.failures)
+	}
+}
+
+func TestWithTaskTokensCallbackErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		deleted bool
+	}{
+		{"throttled", awserr.New("ThrottlingException", "slow down", nil), false},
+		{"timed out", awserr.New(sfn.ErrCodeTaskTimedOut, "too late", nil), true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := &fakeSQSClient{}
+			handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+				return nil
+			}, WithTaskTokens(&fakeSFNClient{successErr: c.err}, nil))
+
+			handler.ProcessMessages(context.Background(), []events.SQSMessage{taskMessage("1", "token")})
+
+			if deleted := len(client.deleted) == 1; deleted != c.deleted {
+				t.Errorf("expected deleted to be %v, got %v", c.deleted, client.deleted)
+			}
+		})
+	}
+}
+
+func TestWithTaskTokensUnencodableOutput(t *testing.T) {
+	client, sfnClient := &fakeSQSClient{}, &fakeSFNClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		SetOutput(ctx, make(chan int))
+		return nil
+	}, WithTaskTokens(sfnClient, nil), WithDeadLetterQueue(testDeadLetterURL), WithLogger(nil))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{taskMessage("1", "token")})
+
+	// output that can never be encoded fails the message permanently
+	if len(client.sent) != 1 || len(client.deleted) != 1 {
+		t.Errorf("expected the message to be dead-lettered, got %d sent and %v deleted", len(client.sent), client.deleted)
+	}
+	if len(sfnClient.failures) != 1 {
+		t.Errorf("expected the task to be failed, got %d failures", len(sfnClient.failures))
+	}
+}
+
+func TestTaskTokenFromBody(t *testing.T) {
+	source := TaskTokenFromBody("$.callback.token")
+
+	msg := testMessage("1")
+	msg.Body = `{"callback": {"token": "abc"}}`
+	if token := source(msg); token != "abc" {
+		t.Errorf("expected token abc, got %q", token)
+	}
+
+	msg.Body = "not json"
+	if token := source(msg); token != "" {
+		t.Errorf("expected no token, got %q", token)
+	}
+}
+
+func TestFailTaskTruncatesCause(t *testing.T) {
+	sfnClient := &fakeSFNClient{}
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		return Permanent(errors.New(strings.Repeat("x", maxTaskFailureCause+1)))
+	}, WithTaskTokens(sfnClient, nil))
+
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{taskMessage("1", "token")})
+
+	if len(sfnClient.failures) != 1 || len(*sfnClient.failures[0].Cause) != maxTaskFailureCause {
+		t.Errorf("expected the cause to be truncated, got %+v", sfnClient.failures)
+	}
+}
@@ -0,0 +1,45 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrMessageTimeout is reported, as a transient failure, for messages that took longer
+// than the timeout set with WithMessageTimeout to process.
+var ErrMessageTimeout = errors.New("message timed out")
+
+// runWithTimeout runs the processor, retries included, within the message timeout.  If
+// the timeout passes first, the processor's context is cancelled and the message fails
+// straight away, without waiting for a processor that's stopped responding.
+func (s *Handler) runWithTimeout(ctx context.Context, msg events.SQSMessage) error {
+	if s.messageTimeout <= 0 {
+		return s.runProcessor(ctx, msg)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, s.messageTimeout, ErrMessageTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.runProcessor(ctx, msg)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && context.Cause(ctx) == ErrMessageTimeout {
+			return Transient(fmt.Errorf("%w after %v: %v", ErrMessageTimeout, s.messageTimeout, err))
+		}
+		return err
+	case <-ctx.Done():
+		// the batch itself was cancelled, so leave the processor to wrap up as usual
+		if context.Cause(ctx) != ErrMessageTimeout {
+			return <-done
+		}
+		s.logMessage(ctx, "message %s timed out after %v, abandoning its processor", msg.MessageId, s.messageTimeout)
+		return Transient(fmt.Errorf("%w after %v", ErrMessageTimeout, s.messageTimeout))
+	}
+}
@@ -0,0 +1,65 @@
+package sqsworker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithMessageTimeout(t *testing.T) {
+	client := &fakeSQSClient{}
+	hung := make(chan struct{})
+	defer close(hung)
+
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		switch msg.MessageId {
+		case "hung":
+			// ignores its context entirely
+			<-hung
+		case "slow":
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}, WithMessageTimeout(20*time.Millisecond), WithLogger(nil))
+
+	start := time.Now()
+	completed, err := handler.ProcessMessages(context.Background(), []events.SQSMessage{
+		testMessage("hung"), testMessage("slow"), testMessage("fast"),
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the batch not to wait on the hung processor, took %v", elapsed)
+	}
+	if completed != 1 || len(client.deleted) != 1 || client.deleted[0] != "handle-fast" {
+		t.Errorf("expected only the fast message to complete, got %d: %v", completed, client.deleted)
+	}
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 2 {
+		t.Fatalf("expected two messages to time out, got %v", err)
+	}
+	for _, msgErr := range batchErr.Errors {
+		if !errors.Is(msgErr.Err, ErrMessageTimeout) || classifyError(nil, msgErr.Err) != ClassTransient {
+			t.Errorf("expected message %s to time out as a transient failure, got %v", msgErr.MessageID, msgErr.Err)
+		}
+	}
+}
+
+func TestMessageTimeoutBatchCancelled(t *testing.T) {
+	handler := NewHandler(&fakeSQSClient{}, func(ctx context.Context, msg events.SQSMessage) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithMessageTimeout(time.Minute), WithLogger(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := handler.runWithTimeout(ctx, testMessage("1"))
+	if errors.Is(err, ErrMessageTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the invocation's deadline to be reported rather than a timeout, got %v", err)
+	}
+}
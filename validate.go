@@ -0,0 +1,82 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// InvalidAction is what ValidateJSONSchema does with messages that fail validation.
+type InvalidAction int
+
+const (
+	// InvalidFail fails the message, leaving it on the queue.
+	InvalidFail InvalidAction = iota
+	// InvalidDrop completes the message without processing it, logging the validation
+	// error.
+	InvalidDrop
+	// InvalidDeadLetter fails the message permanently, so that it's dead-lettered with
+	// the validation errors attached when a dead-letter queue is configured.
+	InvalidDeadLetter
+)
+
+// ValidationError is reported for messages whose body doesn't match the schema.
+type ValidationError struct {
+	MessageID string
+	Err       error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("message %s is invalid: %v", e.MessageID, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateJSONSchema creates a Middleware that validates each message body against the
+// given JSON Schema before the processor runs, catching producer bugs at the boundary.
+// Invalid messages, including bodies that aren't JSON at all, are handled according
+// to onInvalid.  An error is returned if the schema itself is invalid.
+func ValidateJSONSchema(schema []byte, onInvalid InvalidAction) (Middleware, error) {
+	const url = "sqsworker://schema.json"
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, bytes.NewReader(schema)); err != nil {
+		return nil, err
+	}
+
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next MessageProcessor) MessageProcessor {
+		return func(ctx context.Context, msg events.SQSMessage) error {
+			var body interface{}
+			err := json.Unmarshal([]byte(msg.Body), &body)
+			if err == nil {
+				err = compiled.Validate(body)
+			}
+
+			if err == nil {
+				return next(ctx, msg)
+			}
+
+			invalid := &ValidationError{MessageID: msg.MessageId, Err: err}
+			switch onInvalid {
+			case InvalidDrop:
+				Logf(ctx, "dropping invalid message %s: %v", msg.MessageId, err)
+				return nil
+			case InvalidDeadLetter:
+				return Permanent(invalid)
+			default:
+				return invalid
+			}
+		}
+	}, nil
+}
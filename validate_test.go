@@ -0,0 +1,107 @@
+package sqsworker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const testOrderSchema = `{
+  "type": "object",
+  "required": ["id"],
+  "properties": {"id": {"type": "integer"}}
+}`
+
+func TestValidateJSONSchema(t *testing.T) {
+	for _, action := range []InvalidAction{InvalidFail, InvalidDrop, InvalidDeadLetter} {
+		validate, err := ValidateJSONSchema([]byte(testOrderSchema), action)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		calls := 0
+		processor := validate(func(ctx context.Context, msg events.SQSMessage) error {
+			calls++
+			return nil
+		})
+
+		msg := testMessage("1")
+		msg.Body = `{"id": 1}`
+		if err := processor(context.Background(), msg); err != nil || calls != 1 {
+			t.Errorf("expected a valid message to be processed, got %v", err)
+		}
+
+		for _, body := range []string{`{"id": "one"}`, `not json`} {
+			msg.Body = body
+			err := processor(context.Background(), msg)
+
+			var invalid *ValidationError
+			switch action {
+			case InvalidDrop:
+				if err != nil {
+					t.Errorf("expected %s to be dropped, got %v", body, err)
+				}
+			case InvalidDeadLetter:
+				if !errors.As(err, &invalid) || classifyError(nil, err) != ClassPermanent {
+					t.Errorf("expected %s to be dead-lettered, got %v", body, err)
+				}
+			default:
+				if !errors.As(err, &invalid) || classifyError(nil, err) != ClassTransient {
+					t.Errorf("expected %s to fail, got %v", body, err)
+				}
+			}
+		}
+
+		if calls != 1 {
+			t.Errorf("expected invalid messages to skip the processor, got %d calls", calls)
+		}
+	}
+}
+
+func TestValidateJSONSchemaDropLogged(t *testing.T) {
+	validate, err := ValidateJSONSchema([]byte(testOrderSchema), InvalidDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithMiddleware(validate), WithLogger(log.New(&buf, "", 0)))
+
+	msg := testMessage("1")
+	msg.Body = `{"id": "one"}`
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{msg})
+
+	if !strings.Contains(buf.String(), "dropping invalid message 1: ") || len(client.deleted) != 1 {
+		t.Errorf("expected the dropped message to be logged and deleted, got %q", buf.String())
+	}
+}
+
+func TestValidateJSONSchemaDeadLetterDetails(t *testing.T) {
+	validate, _ := ValidateJSONSchema([]byte(testOrderSchema), InvalidDeadLetter)
+	client := &fakeSQSClient{}
+	handler := NewHandler(client, func(ctx context.Context, msg events.SQSMessage) error {
+		return nil
+	}, WithMiddleware(validate), WithDeadLetterQueue(testDeadLetterURL))
+
+	msg := testMessage("1")
+	msg.Body = `{}`
+	handler.ProcessMessages(context.Background(), []events.SQSMessage{msg})
+
+	if len(client.sent) != 1 || !strings.Contains(*client.sent[0].MessageAttributes[FailureAttribute].StringValue, "missing properties") {
+		t.Errorf("expected the validation errors to be attached to the dead-lettered message")
+	}
+}
+
+func TestValidateJSONSchemaInvalidSchema(t *testing.T) {
+	if _, err := ValidateJSONSchema([]byte(`{"type": 5}`), InvalidFail); err == nil {
+		t.Error("expected an invalid schema to be rejected")
+	}
+}